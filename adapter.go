@@ -0,0 +1,144 @@
+package expressgo
+
+import (
+	"net/http"
+)
+
+// The wildcard capturing the path under the mount point of an http.Handler.
+const handlerPathParam = "handlerPath"
+
+// An http.ResponseWriter handed to net/http middlewares, which writes to the ResponseWriter under the middleware.
+//
+// Headers written by the middleware itself are committed with hooks of the response, so the response sees them as sent.
+type baseWriter struct {
+	res    *Response
+	native http.ResponseWriter
+	// whether the status code and headers have been written to native
+	committed bool
+}
+
+func (w *baseWriter) Header() http.Header {
+	return w.native.Header()
+}
+
+func (w *baseWriter) WriteHeader(statusCode int) {
+	if w.committed {
+		return
+	}
+
+	// written by the middleware, instead of through the response
+	if !w.res.headersSent {
+		w.res.statusCode = statusCode
+
+		hooks := w.res.onHeaders
+		w.res.onHeaders = nil
+		for _, hook := range hooks {
+			hook()
+		}
+		statusCode = w.res.StatusCode()
+	}
+
+	w.native.WriteHeader(statusCode)
+	w.committed = true
+	w.res.headersSent = true
+}
+
+func (w *baseWriter) Write(p []byte) (int, error) {
+	if !w.committed {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	return w.native.Write(p)
+}
+
+// For http.ResponseController to reach the underlying ResponseWriter.
+func (w *baseWriter) Unwrap() http.ResponseWriter {
+	return w.native
+}
+
+// Use a net/http middleware as a callback, e.g., expressgo.Wrap(handlers.CompressHandler).
+//
+// The rest of the chain is run inside the middleware when it calls the next handler, so the middleware could act on the response afterwards, and requests passed to the next handler, e.g., with a new context, are set to req.Native.
+//
+// If the middleware does not call the next handler, the response is ended.
+func Wrap(middleware func(http.Handler) http.Handler) Callback {
+	return func(req *Request, res *Response, next *Next) {
+		native := res.native
+		called := false
+
+		handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if called {
+				return
+			}
+			called = true
+
+			// the rest of the chain writes through the ResponseWriter wrapped by the middleware
+			req.Native = r
+			res.native = w
+			defer func() { res.native = native }()
+
+			// go on with the next callback, or the next list of callbacks after the last one
+			next.Next = true
+			next.Route = true
+			(&UserHandler{app: req.app}).resume(req, res, next)
+		}))
+
+		handler.ServeHTTP(&baseWriter{res: res, native: native}, req.Native)
+
+		if !called {
+			res.End()
+		}
+	}
+}
+
+// Use callbacks as a net/http middleware, e.g., to run middlewares of expressgo in other routers.
+//
+// The next handler is run after the callbacks if the last callback calls next.Next. Errors not handled by the callbacks are answered with 500.
+func Middleware(callbacks ...Callback) func(http.Handler) http.Handler {
+	app := CreateServer()
+
+	return func(h http.Handler) http.Handler {
+		var last Callback = func(req *Request, res *Response, next *Next) {
+			h.ServeHTTP(res.Writer(), req.Native)
+		}
+		var handleError ErrorCallback = func(err error, req *Request, res *Response, next *Next) {
+			res.Status(http.StatusInternalServerError).Send(http.StatusText(http.StatusInternalServerError))
+		}
+
+		chain := [][]Callback{
+			wrapCallbacks(append(callbacks[:len(callbacks):len(callbacks)], last)),
+			wrapErrorCallbacks([]ErrorCallback{handleError}),
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			u := &UserHandler{app: &app}
+			u.serve(w, r, &routing{callbacks: chain})
+		})
+	}
+}
+
+// Serve the http.Handler with the path under the mount point, e.g., /debug/pprof/heap -> /pprof/heap for a handler mounted on /debug.
+func serveHandler(handler http.Handler) Callback {
+	return func(req *Request, res *Response, next *Next) {
+		r := req.Native.Clone(req.Native.Context())
+		r.URL.Path = "/" + req.Params[handlerPathParam]
+		r.URL.RawPath = ""
+
+		handler.ServeHTTP(res.Writer(), r)
+		res.End()
+	}
+}
+
+// To mount an http.Handler on the path with all http methods, e.g., net/http/pprof or Prometheus.
+//
+// Requests to the path and paths under it are served by the handler, with the path prefix removed as http.StripPrefix does.
+func (app *App) UseHandler(path string, handler http.Handler) error {
+	wc := wrapCallbacks([]Callback{serveHandler(handler)})
+	return app.use(joinPath(path, "/*"+handlerPathParam), wc, false)
+}
+
+// To mount an http.Handler on the path relative to the router with all http methods. See app.UseHandler.
+func (r *Router) UseHandler(path string, handler http.Handler) error {
+	wc := wrapCallbacks([]Callback{serveHandler(handler)})
+	return r.use(joinPath(path, "/*"+handlerPathParam), wc, false)
+}
@@ -0,0 +1,153 @@
+package expressgo
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type gzipWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipWriter) WriteHeader(statusCode int) {
+	w.ResponseWriter.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	return w.gz.Write(p)
+}
+
+// A middleware acting on the response after the next handler returns.
+func gzipMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		h.ServeHTTP(&gzipWriter{ResponseWriter: w, gz: gz}, r)
+	})
+}
+
+type contextKey struct{}
+
+// A middleware passing a new request to the next handler.
+func contextMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, "value")))
+	})
+}
+
+// A middleware responding without calling the next handler.
+func authMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func serveWithHeader(h http.Handler, target string, field string, value string) *http.Response {
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	if value != "" {
+		r.Header.Set(field, value)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Result()
+}
+
+func TestWrap(t *testing.T) {
+	app := CreateServer()
+	app.UseGlobal(Wrap(contextMiddleware), Wrap(authMiddleware))
+	app.Get("/", Wrap(gzipMiddleware), func(req *Request, res *Response, next *Next) {
+		res.Send("hello " + req.Native.Context().Value(contextKey{}).(string))
+	})
+
+	resp := serveWithHeader(&app, "/", "Authorization", "token")
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("body is not compressed: %v", err)
+	}
+	body, _ := io.ReadAll(gz)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello value" {
+		t.Errorf("got %d %q, want 200 %q", resp.StatusCode, body, "hello value")
+	}
+	if resp.Header.Get("Content-Length") != "" {
+		t.Errorf("Content-Length of the uncompressed body is sent")
+	}
+
+	resp = serveWithHeader(&app, "/", "Authorization", "")
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("got %d, want 401", resp.StatusCode)
+	}
+}
+
+func TestUseHandlerAndMiddleware(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path)
+	})
+
+	app := CreateServer()
+	app.UseHandler("/debug", mux)
+
+	for target, want := range map[string]string{"/debug": "/", "/debug/": "/", "/Debug/pprof/Heap?debug=1": "/pprof/Heap"} {
+		body, _ := io.ReadAll(serveWithHeader(&app, target, "", "").Body)
+		if string(body) != want {
+			t.Errorf("%s: got %q, want %q", target, body, want)
+		}
+	}
+
+	h := Middleware(func(req *Request, res *Response, next *Next) {
+		if req.Get("X-Fail") != "" {
+			next.Err = errors.New("failed")
+			return
+		}
+		res.Set("X-Middleware", "expressgo")
+		next.Next = true
+	})(mux)
+
+	resp := serveWithHeader(h, "/path", "", "")
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "/path" || resp.Header.Get("X-Middleware") != "expressgo" {
+		t.Errorf("got %q with %v, want the next handler run after callbacks", body, resp.Header)
+	}
+
+	if resp := serveWithHeader(h, "/path", "X-Fail", "1"); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got %d, want 500 for unhandled errors", resp.StatusCode)
+	}
+}
+
+func TestAppAsHandler(t *testing.T) {
+	app := CreateServer()
+	app.Get("/", func(req *Request, res *Response, next *Next) {
+		res.Send("root")
+	})
+	app.Get("/users", func(req *Request, res *Response, next *Next) {
+		res.Send("users")
+	})
+
+	// a request to the prefix has an empty path under http.StripPrefix
+	h := http.StripPrefix("/api", &app)
+	for target, want := range map[string]string{"/api": "root", "/api/": "root", "/api/Users": "users"} {
+		body, _ := io.ReadAll(serveWithHeader(h, target, "", "").Body)
+		if string(body) != want {
+			t.Errorf("%s: got %q, want %q", target, body, want)
+		}
+	}
+
+	// the request of the caller is not rewritten
+	r := httptest.NewRequest(http.MethodGet, "/Users", nil)
+	app.ServeHTTP(httptest.NewRecorder(), r)
+	if r.URL.Path != "/Users" || r.Pattern != "" {
+		t.Errorf("got %q %q, want the request kept as it is", r.URL.Path, r.Pattern)
+	}
+}
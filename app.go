@@ -1,35 +1,88 @@
 package expressgo
 
 import (
-	"flag"
-	"log"
 	"net/http"
 	"os"
-	"strconv"
+	"regexp"
+	"sync"
+	"time"
 )
 
 type appConfig struct {
 	allowHost     bool
 	coarse        bool
 	caseSensitive bool
+	// answer HEAD requests with GET routes
+	autoHead bool
+	// answer OPTIONS requests with the Allow header
+	autoOptions bool
+	// decide if proxies are trusted, compiled from "trust proxy"
+	trustProxy trustFunc
+	// number of dot-separated parts removed from host names for subdomains
+	subdomainOffset int
+	// options of servers, see Config
+	h2c               bool
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+}
+
+// Information of a list of callbacks registered with a route.
+type listInfo struct {
+	// registered by app.Use, app.UseError, or global callbacks, which do not answer requests on their own
+	middleware bool
+	// params of the path the list is registered with, divided by param zones, e.g., [["one", "0H", "two"], ["three"]]
+	params [][]string
+	// regex constraints of the params, param1 -> constraint1
+	constraints map[string]*regexp.Regexp
+	// params which should have values, the ones not in optional segments
+	required []string
 }
 
 type App struct {
+	// values shared by all requests of the app, which should only be written before serving, see Locals
+	Locals Locals
 	config *appConfig
+	// guards config, data, and all callbacks and params, which could be read while serving
+	mu *sync.RWMutex
 	// global data table for app
 	data    map[string]interface{}
 	handler *Handler
 	// multiple lists of callbacks associated with a route, routeA -> [[c11, c12, c13], [c21, c22]]
 	callbacks map[string][][]Callback
+	// information of lists of callbacks associated with a route, in the same order as callbacks
+	lists map[string][]listInfo
 	// lists of callbacks, format: [[c11, c12, c13], [c21, c22]], set by app.UseGlobal
 	globalCallbacks *[][]Callback
-	// params associated with a route, routeA -> [[param1, param2], [param3]]
-	params map[string][][]string
+	// index of the first list of callbacks registered with a route, after global callbacks registered before it
+	routeStarts map[string]int
+	// callbacks for params set by app.Param, wrapped when they are set, param1 -> [c1, c2]
+	paramCallbacks map[string][]Callback
+	// lists of error callbacks set by app.UseGlobalError, which are also in globalCallbacks
+	globalErrorCallbacks *[][]Callback
+	// lists of callbacks for requests matching no routes, set by app.NotFound
+	notFoundCallbacks *[][]Callback
+	// lists of callbacks for requests matching routes of other methods only, set by app.MethodNotAllowed
+	methodNotAllowedCallbacks *[][]Callback
+	// functions called after servers start listening, set by app.OnListen
+	listenHooks *[]func(server *Server)
+	// functions called after servers are stopped, set by app.OnShutdown
+	shutdownHooks *[]func()
 }
 
 type Config struct {
 	AllowHost bool
 	Coarse    bool
+	// serve HTTP/2 without TLS (h2c), e.g., behind proxies or service meshes speaking HTTP/2 in cleartext
+	H2C bool
+	// timeouts and limits of servers started by app.Listen, app.ListenTLS, app.ListenUnix, and app.ListenOn, 0 for defaults of net/http
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
 }
 
 func CreateServer(config ...Config) App {
@@ -37,38 +90,42 @@ func CreateServer(config ...Config) App {
 
 	// perform the configuration, config is made to a slice to mimic behaviors of optional parameters
 	app := App{
-		config:          &appConfig{},
-		data:            map[string]interface{}{},
-		handler:         &Handler{mux: mux},
-		callbacks:       map[string][][]Callback{},
-		globalCallbacks: &[][]Callback{},
-		params:          map[string][][]string{},
+		Locals:                    Locals{},
+		config:                    &appConfig{autoHead: true, autoOptions: true, trustProxy: trustNone, subdomainOffset: 2},
+		mu:                        &sync.RWMutex{},
+		data:                      map[string]interface{}{},
+		handler:                   &Handler{mux: mux},
+		callbacks:                 map[string][][]Callback{},
+		lists:                     map[string][]listInfo{},
+		globalCallbacks:           &[][]Callback{},
+		routeStarts:               map[string]int{},
+		paramCallbacks:            map[string][]Callback{},
+		globalErrorCallbacks:      &[][]Callback{},
+		notFoundCallbacks:         &[][]Callback{},
+		methodNotAllowedCallbacks: &[][]Callback{},
+		listenHooks:               &[]func(server *Server){},
+		shutdownHooks:             &[]func(){},
 	}
 	app.handler.app = &app
 	if len(config) > 0 {
 		c := config[0]
 		app.config.allowHost = c.AllowHost
 		app.config.coarse = c.Coarse
+		app.config.h2c = c.H2C
+		app.config.readTimeout = c.ReadTimeout
+		app.config.readHeaderTimeout = c.ReadHeaderTimeout
+		app.config.writeTimeout = c.WriteTimeout
+		app.config.idleTimeout = c.IdleTimeout
+		app.config.maxHeaderBytes = c.MaxHeaderBytes
 	}
 
-	return app
-}
-
-func (app *App) Listen(port int) {
-	// set APP_ENV with the shell level env
+	// set APP_ENV with the shell level env, which could be overridden by app.Set
 	app.Set(configKeyAppEnv, os.Getenv("APP_ENV"))
 
-	// set APP_ENV with the command level env
-	var appEnv string
-	flag.StringVar(&appEnv, "mode", "", "APP_ENV")
-	flag.Parse()
-	if appEnv != "" {
-		app.Set(configKeyAppEnv, appEnv)
-	}
+	return app
+}
 
-	log.Println("expressgo listens to port: " + strconv.Itoa(port))
-	err := http.ListenAndServe(":"+strconv.Itoa(port), app.handler)
-	if err != nil {
-		log.Fatalln(err)
-	}
+// Serve a request with the app, so the app could be used as an http.Handler, e.g., with httptest.
+func (app *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	app.handler.ServeHTTP(w, r)
 }
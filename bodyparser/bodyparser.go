@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/mime"
 )
 
 var ErrEu = errors.New("415: encoding.unsupported")
@@ -15,16 +16,10 @@ var ErrEvf = errors.New("403: entity.verify.failed")
 
 // Check if the received type is the expected type.
 func isContentType(value string, expectedType any) bool {
-	receivedType := strings.ToLower(strings.TrimSpace(strings.Split(value, ";")[0]))
-
 	if e, ok := expectedType.(string); ok {
-		return mimeMatch(receivedType, normalize(e))
+		return mime.Is(value, e) != ""
 	} else if es, ok := expectedType.([]string); ok {
-		for _, e := range es {
-			if mimeMatch(receivedType, normalize(e)) {
-				return true
-			}
-		}
+		return len(es) > 0 && mime.Is(value, es...) != ""
 	}
 
 	return false
@@ -0,0 +1,337 @@
+package bodyparser
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+
+	"github.com/Eandalf/expressgo"
+)
+
+var ErrMb = errors.New("400: multipart.boundary.missing")
+var ErrLimitPartCount = errors.New("413: limit.part.count")
+var ErrLimitFileSize = errors.New("413: limit.file.size")
+var ErrLimitFileCount = errors.New("413: limit.file.count")
+var ErrLimitFieldKey = errors.New("413: limit.field.key")
+var ErrLimitFieldValue = errors.New("413: limit.field.value")
+var ErrLimitFieldCount = errors.New("413: limit.field.count")
+var ErrLimitUnexpectedFile = errors.New("400: limit.unexpected.file")
+
+// Decide whether the file should be saved, rejected files are skipped. Returning an error fails the request.
+type FileFilter func(req *expressgo.Request, file *expressgo.File) (bool, error)
+
+// Sizes are expected to be int64 or string (e.g., "10mb"), and counts of 0 are unlimited.
+type MultipartLimits struct {
+	// max size of field names, defaults to 100 bytes
+	FieldNameSize    any
+	fieldNameSizeNum int64
+	// max size of field values, defaults to 1mb
+	FieldSize    any
+	fieldSizeNum int64
+	// max number of text fields
+	Fields int
+	// max size of each file, defaults to unlimited
+	FileSize    any
+	fileSizeNum int64
+	// max number of files
+	Files int
+	// max number of parts, fields and files
+	Parts int
+}
+
+type MultipartConfig struct {
+	// defaults to MemoryStorage()
+	Storage Storage
+	// max size of the whole body, expected type: int64 or string, defaults to unlimited
+	Limit    any
+	limitNum int64
+	Limits   MultipartLimits
+	// defaults to accepting all files
+	FileFilter FileFilter
+	// keep the full path of file names sent by clients instead of the base name
+	PreservePath bool
+}
+
+// Fields accepted by MultipartParser.Fields, a MaxCount of 0 is unlimited.
+type MultipartField struct {
+	Name     string
+	MaxCount int
+}
+
+// Create middlewares parsing multipart/form-data bodies, text fields into req.Body and files into req.Files.
+type MultipartParser struct {
+	config MultipartConfig
+}
+
+// Read at most n bytes, and fail with err beyond that.
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.err
+	}
+
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	// bytes beyond the limit are dropped, so readers buffering ahead could not miss the error
+	if l.n < 0 {
+		return 0, l.err
+	}
+	return n, err
+}
+
+// Wrap the reader with the limit, limits less than 0 are unlimited.
+func limitReader(r io.Reader, limit int64, err error) io.Reader {
+	if limit < 0 {
+		return r
+	}
+
+	return &limitedReader{r, limit, err}
+}
+
+// Parse a size limit, nil for the default.
+func parseLimit(limit any, defaultLimit int64) int64 {
+	if limit == nil {
+		return defaultLimit
+	}
+
+	return parseByte(limit)
+}
+
+func Multipart(multipartConfig ...MultipartConfig) *MultipartParser {
+	// the default config
+	config := MultipartConfig{
+		Storage:  MemoryStorage(),
+		limitNum: -1,
+	}
+
+	// merge configs
+	if len(multipartConfig) > 0 {
+		userConfig := multipartConfig[0]
+
+		if userConfig.Storage != nil {
+			config.Storage = userConfig.Storage
+		}
+		config.limitNum = parseLimit(userConfig.Limit, -1)
+		config.Limits = userConfig.Limits
+		if userConfig.FileFilter != nil {
+			config.FileFilter = userConfig.FileFilter
+		}
+		if userConfig.PreservePath {
+			config.PreservePath = userConfig.PreservePath
+		}
+	}
+
+	config.Limits.fieldNameSizeNum = parseLimit(config.Limits.FieldNameSize, 100)
+	config.Limits.fieldSizeNum = parseLimit(config.Limits.FieldSize, 1<<20)
+	config.Limits.fileSizeNum = parseLimit(config.Limits.FileSize, -1)
+
+	return &MultipartParser{config}
+}
+
+// Accept a single file of the field.
+func (p *MultipartParser) Single(name string) expressgo.Callback {
+	return p.createParser(map[string]int{name: 1})
+}
+
+// Accept files of the field, at most maxCount files if it is positive.
+func (p *MultipartParser) Array(name string, maxCount int) expressgo.Callback {
+	return p.createParser(map[string]int{name: maxCount})
+}
+
+// Accept files of the fields.
+func (p *MultipartParser) Fields(fields []MultipartField) expressgo.Callback {
+	allowed := map[string]int{}
+	for _, field := range fields {
+		allowed[field.Name] = field.MaxCount
+	}
+
+	return p.createParser(allowed)
+}
+
+// Accept files of any fields.
+func (p *MultipartParser) Any() expressgo.Callback {
+	return p.createParser(nil)
+}
+
+// Accept text fields only.
+func (p *MultipartParser) None() expressgo.Callback {
+	return p.createParser(map[string]int{})
+}
+
+// Get the file name from Content-Disposition, ok is false if the part is not a file.
+func partFilename(part *multipart.Part, preservePath bool) (string, bool) {
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if err != nil {
+		return "", false
+	}
+
+	if _, ok := params["filename"]; !ok {
+		return "", false
+	}
+	if preservePath {
+		return params["filename"], true
+	}
+
+	// the base name without directories
+	return part.FileName(), true
+}
+
+// Read all parts, files are saved to the storage and appended to stored even if an error occurs.
+func (p *MultipartParser) read(
+	req *expressgo.Request,
+	mr *multipart.Reader,
+	allowed map[string]int,
+	fields expressgo.BodyFormUrlEncoded,
+	files map[string][]*expressgo.File,
+	stored *[]*expressgo.File,
+) error {
+	limits := p.config.Limits
+	partCount, fieldCount, fileCount := 0, 0, 0
+
+	for {
+		// raw parts are kept as they are sent, as multer does
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		partCount++
+		if limits.Parts > 0 && partCount > limits.Parts {
+			return ErrLimitPartCount
+		}
+
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if int64(len(name)) > limits.fieldNameSizeNum {
+			return ErrLimitFieldKey
+		}
+
+		filename, isFile := partFilename(part, p.config.PreservePath)
+
+		// text fields
+		if !isFile {
+			fieldCount++
+			if limits.Fields > 0 && fieldCount > limits.Fields {
+				return ErrLimitFieldCount
+			}
+
+			value, err := io.ReadAll(limitReader(part, limits.fieldSizeNum, ErrLimitFieldValue))
+			if err != nil {
+				return err
+			}
+
+			// we only accept the first value of a name, as the urlencoded parser does
+			if _, ok := fields[name]; !ok {
+				fields[name] = string(value)
+			}
+			continue
+		}
+
+		// files
+		fileCount++
+		if limits.Files > 0 && fileCount > limits.Files {
+			return ErrLimitFileCount
+		}
+
+		if allowed != nil {
+			maxCount, ok := allowed[name]
+			if !ok || (maxCount > 0 && len(files[name]) >= maxCount) {
+				return ErrLimitUnexpectedFile
+			}
+		}
+
+		encoding := part.Header.Get("Content-Transfer-Encoding")
+		if encoding == "" {
+			encoding = "7bit"
+		}
+		file := &expressgo.File{
+			FieldName:    name,
+			OriginalName: filename,
+			Encoding:     encoding,
+			MimeType:     part.Header.Get("Content-Type"),
+		}
+
+		if p.config.FileFilter != nil {
+			accepted, err := p.config.FileFilter(req, file)
+			if err != nil {
+				return err
+			}
+			// the rest of the part is skipped by the next NextRawPart
+			if !accepted {
+				continue
+			}
+		}
+
+		if err := p.config.Storage.HandleFile(req, file, limitReader(part, limits.fileSizeNum, ErrLimitFileSize)); err != nil {
+			return err
+		}
+
+		*stored = append(*stored, file)
+		files[name] = append(files[name], file)
+	}
+}
+
+// Remove the saved files.
+func (p *MultipartParser) remove(req *expressgo.Request, files []*expressgo.File) {
+	for _, file := range files {
+		p.config.Storage.RemoveFile(req, file)
+	}
+}
+
+func (p *MultipartParser) createParser(allowed map[string]int) expressgo.Callback {
+	parser := func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		if isContentType(req.Native.Header.Get("Content-Type"), "multipart/form-data") {
+			_, params, err := mime.ParseMediaType(req.Native.Header.Get("Content-Type"))
+			if err != nil || params["boundary"] == "" {
+				next.Err = ErrMb
+				return
+			}
+
+			body := limitReader(req.Native.Body, p.config.limitNum, ErrEtl)
+			mr := multipart.NewReader(body, params["boundary"])
+
+			fields := expressgo.BodyFormUrlEncoded{}
+			files := map[string][]*expressgo.File{}
+			stored := []*expressgo.File{}
+
+			// temporary files are removed when the request ends, even if reading fails or a later callback panics
+			if ds, ok := p.config.Storage.(*diskStorage); ok && ds.temporary {
+				res.OnFinish(func() {
+					p.remove(req, stored)
+				})
+			}
+
+			if err := p.read(req, mr, allowed, fields, files, &stored); err != nil {
+				// files saved before the error are useless
+				p.remove(req, stored)
+				stored = nil
+				// the error of the body limit might be wrapped by multipart.Reader
+				if errors.Is(err, ErrEtl) {
+					err = ErrEtl
+				}
+				next.Err = err
+				return
+			}
+
+			req.Body = fields
+			req.Files = files
+		}
+
+		// proceed to the next callback
+		next.Next = true
+		next.Route = true
+	}
+
+	return parser
+}
@@ -0,0 +1,188 @@
+package bodyparser_test
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/bodyparser"
+	"github.com/Eandalf/expressgo/expressgotest"
+)
+
+// A part of a multipart body, a file if filename is set.
+type part struct {
+	name     string
+	filename string
+	value    string
+}
+
+// Encode parts into a multipart body, and return it with its Content-Type.
+func encode(parts ...part) (string, string) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	for _, p := range parts {
+		if p.filename != "" {
+			f, _ := w.CreateFormFile(p.name, p.filename)
+			f.Write([]byte(p.value))
+		} else {
+			w.WriteField(p.name, p.value)
+		}
+	}
+	w.Close()
+
+	return buf.String(), w.FormDataContentType()
+}
+
+// Respond with the error as the body.
+func sendError(err error, req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+	res.Status(400).Send(err.Error())
+}
+
+func TestMultipart(t *testing.T) {
+	app := expressgo.CreateServer()
+	upload := bodyparser.Multipart()
+
+	app.Post("/any", upload.Any(), func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		body := req.Body.(expressgo.BodyFormUrlEncoded)
+		file := req.Files["doc"][0]
+		res.Send(fmt.Sprintf("%s %s %s %s %d %d", body["name"], file.OriginalName, file.MimeType, file.Buffer, file.Size, len(req.Files["other"])))
+	})
+	app.Post("/single", upload.Single("doc"), func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Send(fmt.Sprint(len(req.Files["doc"])))
+	})
+	app.Post("/none", upload.None(), func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Send(req.Body.(expressgo.BodyFormUrlEncoded)["name"])
+	})
+	app.UseGlobalError(sendError)
+
+	agent := expressgotest.New(&app)
+
+	// fields and files are parsed, and the first value of a field is kept
+	body, ctype := encode(part{"name", "", "tobi"}, part{"name", "", "loki"}, part{"doc", "dir/a.txt", "hello"})
+	agent.Post("/any").Type(ctype).Send(body).Expect(t).Status(200).Body("tobi a.txt application/octet-stream hello 5 0")
+
+	// files of other fields are rejected by parsers accepting the named fields only
+	body, ctype = encode(part{"doc", "a.txt", "a"})
+	agent.Post("/single").Type(ctype).Send(body).Expect(t).Status(200).Body("1")
+	body, ctype = encode(part{"doc", "a.txt", "a"}, part{"doc", "b.txt", "b"})
+	agent.Post("/single").Type(ctype).Send(body).Expect(t).Status(400).Body(bodyparser.ErrLimitUnexpectedFile.Error())
+	body, ctype = encode(part{"name", "", "tobi"}, part{"doc", "a.txt", "a"})
+	agent.Post("/none").Type(ctype).Send(body).Expect(t).Status(400).Body(bodyparser.ErrLimitUnexpectedFile.Error())
+
+	// a missing boundary fails the request, and other types are passed on
+	agent.Post("/none").Type("multipart/form-data").Send("").Expect(t).Status(400).Body(bodyparser.ErrMb.Error())
+	agent.Post("/none").Send("text").Expect(t).Status(400)
+}
+
+func TestMultipartLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		config bodyparser.MultipartConfig
+		parts  []part
+		err    error
+	}{
+		{"body", bodyparser.MultipartConfig{Limit: 100}, []part{{"doc", "a.txt", strings.Repeat("a", 200)}}, bodyparser.ErrEtl},
+		{"parts", bodyparser.MultipartConfig{Limits: bodyparser.MultipartLimits{Parts: 2}}, []part{{"a", "", "1"}, {"b", "", "2"}, {"c", "a.txt", "3"}}, bodyparser.ErrLimitPartCount},
+		{"fields", bodyparser.MultipartConfig{Limits: bodyparser.MultipartLimits{Fields: 1}}, []part{{"a", "", "1"}, {"b", "", "2"}}, bodyparser.ErrLimitFieldCount},
+		{"files", bodyparser.MultipartConfig{Limits: bodyparser.MultipartLimits{Files: 1}}, []part{{"a", "a.txt", "1"}, {"b", "b.txt", "2"}}, bodyparser.ErrLimitFileCount},
+		{"field name size", bodyparser.MultipartConfig{Limits: bodyparser.MultipartLimits{FieldNameSize: 3}}, []part{{"long", "", "1"}}, bodyparser.ErrLimitFieldKey},
+		{"field size", bodyparser.MultipartConfig{Limits: bodyparser.MultipartLimits{FieldSize: "1kb"}}, []part{{"a", "", strings.Repeat("a", 1025)}}, bodyparser.ErrLimitFieldValue},
+		{"file size", bodyparser.MultipartConfig{Limits: bodyparser.MultipartLimits{FileSize: 4}}, []part{{"a", "a.txt", "hello"}}, bodyparser.ErrLimitFileSize},
+		{"within limits", bodyparser.MultipartConfig{Limit: "1kb", Limits: bodyparser.MultipartLimits{Parts: 2, Fields: 1, Files: 1, FileSize: 5}}, []part{{"a", "", "1"}, {"b", "a.txt", "hello"}}, nil},
+	}
+
+	for _, test := range tests {
+		app := expressgo.CreateServer()
+		app.Post("/", bodyparser.Multipart(test.config).Any(), func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+			res.Send("ok")
+		})
+		app.UseGlobalError(sendError)
+
+		expected := "ok"
+		if test.err != nil {
+			expected = test.err.Error()
+		}
+
+		body, ctype := encode(test.parts...)
+		res := expressgotest.New(&app).Post("/").Type(ctype).Send(body).Expect(t)
+		if res.Text != expected {
+			t.Errorf("%s: expected %q, got %q", test.name, expected, res.Text)
+		}
+	}
+}
+
+func TestMultipartTemporaryFiles(t *testing.T) {
+	// temporary files are saved to os.TempDir()
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	app := expressgo.CreateServer()
+	app.Set("APP_ENV", "development")
+	upload := bodyparser.Multipart(bodyparser.MultipartConfig{
+		Storage: bodyparser.DiskStorage(),
+		Limits:  bodyparser.MultipartLimits{Files: 2},
+	})
+
+	paths := []string{}
+	app.Post("/", upload.Any(), func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		for _, file := range req.Files["doc"] {
+			if _, err := os.Stat(file.Path); err != nil {
+				t.Errorf("expected the file saved, got %v", err)
+			}
+			paths = append(paths, file.Path)
+		}
+
+		switch req.Query["fail"] {
+		case "error":
+			next.Err = fmt.Errorf("failed")
+		case "panic":
+			panic("failed")
+		default:
+			res.Send("ok")
+		}
+	})
+
+	agent := expressgotest.New(&app)
+	checkRemoved := func(name string) {
+		t.Helper()
+		if len(paths) == 0 {
+			t.Errorf("%s: expected files saved", name)
+		}
+		for _, path := range paths {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("%s: expected %s removed, got %v", name, path, err)
+			}
+		}
+		paths = paths[:0]
+	}
+
+	body, ctype := encode(part{"doc", "a.txt", "a"}, part{"doc", "b.txt", "b"})
+	agent.Post("/").Type(ctype).Send(body).Expect(t).Status(200)
+	checkRemoved("sent")
+
+	// files are removed if the chain fails
+	agent.Post("/").Query("fail", "error").Type(ctype).Send(body).Expect(t)
+	checkRemoved("error")
+
+	// panics are not recovered in development environments, and files are still removed
+	func() {
+		defer func() {
+			if r := recover(); r != "failed" {
+				t.Errorf("expected the panic, got %v", r)
+			}
+		}()
+		agent.Post("/").Query("fail", "panic").Type(ctype).Send(body).Expect(t)
+	}()
+	checkRemoved("panic")
+
+	// files saved before a limit is exceeded are removed
+	body, ctype = encode(part{"doc", "a.txt", "a"}, part{"doc", "b.txt", "b"}, part{"doc", "c.txt", "c"})
+	agent.Post("/").Type(ctype).Send(body).Expect(t)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no files left in the temporary directory, got %d", len(entries))
+	}
+}
@@ -0,0 +1,151 @@
+package bodyparser
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Eandalf/expressgo"
+)
+
+// Storage engines save files of multipart bodies.
+type Storage interface {
+	// Save the file read from r, and set Size and where it is saved on the file.
+	HandleFile(req *expressgo.Request, file *expressgo.File, r io.Reader) error
+	// Remove the saved file.
+	RemoveFile(req *expressgo.Request, file *expressgo.File) error
+}
+
+type memoryStorage struct{}
+
+// Create a storage keeping files in memory as File.Buffer.
+func MemoryStorage() Storage {
+	return &memoryStorage{}
+}
+
+func (s *memoryStorage) HandleFile(req *expressgo.Request, file *expressgo.File, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	file.Buffer = b
+	file.Size = int64(len(b))
+	return nil
+}
+
+func (s *memoryStorage) RemoveFile(req *expressgo.Request, file *expressgo.File) error {
+	file.Buffer = nil
+	return nil
+}
+
+type DiskStorageConfig struct {
+	// the directory (string) or func(*expressgo.Request, *expressgo.File) (string, error) returning it
+	//
+	// defaults to os.TempDir(), and files are removed when the request ends
+	Destination any
+	// get the name of the file in the destination, defaults to 16 random bytes in hex without extension
+	Filename func(req *expressgo.Request, file *expressgo.File) (string, error)
+}
+
+type diskStorage struct {
+	destination func(req *expressgo.Request, file *expressgo.File) (string, error)
+	filename    func(req *expressgo.Request, file *expressgo.File) (string, error)
+	// files are temporary if no destination is given
+	temporary bool
+}
+
+// Generate a file name of 16 random bytes in hex.
+func randomFilename(req *expressgo.Request, file *expressgo.File) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Create a storage saving files to the disk.
+func DiskStorage(diskStorageConfig ...DiskStorageConfig) Storage {
+	// the default config
+	storage := &diskStorage{
+		destination: func(req *expressgo.Request, file *expressgo.File) (string, error) {
+			return os.TempDir(), nil
+		},
+		filename:  randomFilename,
+		temporary: true,
+	}
+
+	// merge configs
+	if len(diskStorageConfig) > 0 {
+		userConfig := diskStorageConfig[0]
+
+		if d, ok := userConfig.Destination.(string); ok && d != "" {
+			// the directory is created as multer does for string destinations
+			if err := os.MkdirAll(d, 0o755); err != nil {
+				panic(err)
+			}
+			storage.destination = func(req *expressgo.Request, file *expressgo.File) (string, error) {
+				return d, nil
+			}
+			storage.temporary = false
+		} else if d, ok := userConfig.Destination.(func(req *expressgo.Request, file *expressgo.File) (string, error)); ok {
+			storage.destination = d
+			storage.temporary = false
+		} else if userConfig.Destination != nil {
+			panic(errors.New("destination should be a string or a func(*expressgo.Request, *expressgo.File) (string, error)"))
+		}
+		if userConfig.Filename != nil {
+			storage.filename = userConfig.Filename
+		}
+	}
+
+	return storage
+}
+
+func (s *diskStorage) HandleFile(req *expressgo.Request, file *expressgo.File, r io.Reader) error {
+	destination, err := s.destination(req, file)
+	if err != nil {
+		return err
+	}
+
+	filename, err := s.filename(req, file)
+	if err != nil {
+		return err
+	}
+
+	p := filepath.Join(destination, filename)
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+
+	size, err := io.Copy(f, r)
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(p)
+		return err
+	}
+
+	file.Destination = destination
+	file.FileName = filename
+	file.Path = p
+	file.Size = size
+	return nil
+}
+
+func (s *diskStorage) RemoveFile(req *expressgo.Request, file *expressgo.File) error {
+	if file.Path == "" {
+		return nil
+	}
+
+	if err := os.Remove(file.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package expressgo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrCookieSecret = errors.New("cookie secret is required for signed cookies")
+
+type CookieOptions struct {
+	Domain string
+	// encode the value, defaults to the same as encodeURIComponent in JavaScript
+	Encode   func(string) string
+	Expires  time.Time
+	HttpOnly bool
+	// also sets Expires, only positive durations take effect
+	MaxAge      time.Duration
+	Partitioned bool
+	// defaults to "/"
+	Path string
+	// "low", "medium", or "high"
+	Priority string
+	// true for "Strict", or "lax", "strict", "none"
+	SameSite any
+	Secure   bool
+	// sign the value with req.Secret
+	Signed bool
+}
+
+// Sign the value with the secret, compatible with cookie-signature of Node.js.
+func SignCookie(value string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return value + "." + base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Unsign the signed value with any of the secrets, which are tried in order to support rotating secrets.
+//
+// Return the original value and true if the signature is valid.
+func UnsignCookie(signed string, secrets ...string) (string, bool) {
+	pos := strings.LastIndexByte(signed, '.')
+	if pos < 0 {
+		return "", false
+	}
+
+	value := signed[:pos]
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(SignCookie(value, secret)), []byte(signed)) {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// Encode the string in the same way as encodeURIComponent in JavaScript.
+func encodeURIComponent(s string) string {
+	const unreserved = "-_.!~*'()"
+
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || strings.IndexByte(unreserved, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// Serialize the cookie into a Set-Cookie header value.
+func serializeCookie(name string, value string, options CookieOptions) string {
+	encode := encodeURIComponent
+	if options.Encode != nil {
+		encode = options.Encode
+	}
+
+	cookie := name + "=" + encode(value)
+
+	if options.MaxAge > 0 {
+		cookie += "; Max-Age=" + strconv.FormatInt(int64(options.MaxAge/time.Second), 10)
+	}
+	if options.Domain != "" {
+		cookie += "; Domain=" + options.Domain
+	}
+	if options.Path != "" {
+		cookie += "; Path=" + options.Path
+	}
+	if !options.Expires.IsZero() {
+		cookie += "; Expires=" + options.Expires.UTC().Format(http.TimeFormat)
+	}
+	if options.HttpOnly {
+		cookie += "; HttpOnly"
+	}
+	if options.Secure {
+		cookie += "; Secure"
+	}
+	if options.Partitioned {
+		cookie += "; Partitioned"
+	}
+
+	switch strings.ToLower(options.Priority) {
+	case "low":
+		cookie += "; Priority=Low"
+	case "medium":
+		cookie += "; Priority=Medium"
+	case "high":
+		cookie += "; Priority=High"
+	}
+
+	if b, ok := options.SameSite.(bool); ok && b {
+		cookie += "; SameSite=Strict"
+	} else if s, ok := options.SameSite.(string); ok {
+		switch strings.ToLower(s) {
+		case "lax":
+			cookie += "; SameSite=Lax"
+		case "strict":
+			cookie += "; SameSite=Strict"
+		case "none":
+			cookie += "; SameSite=None"
+		}
+	}
+
+	return cookie
+}
+
+// Set a cookie. Values other than strings are encoded as JSON with the "j:" prefix.
+//
+// Signed cookies are signed with req.Secret, which is set by the cookie parser, and have the "s:" prefix.
+func (res *Response) Cookie(name string, value any, options ...CookieOptions) error {
+	o := CookieOptions{}
+	if len(options) > 0 {
+		o = options[0]
+	}
+
+	v, ok := value.(string)
+	if !ok {
+		j, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		v = "j:" + string(j)
+	}
+
+	if o.Signed {
+		if res.req.Secret == "" {
+			return ErrCookieSecret
+		}
+		v = "s:" + SignCookie(v, res.req.Secret)
+	}
+
+	if o.MaxAge > 0 {
+		o.Expires = time.Now().Add(o.MaxAge)
+	}
+	if o.Path == "" {
+		o.Path = "/"
+	}
+
+	res.Append("Set-Cookie", serializeCookie(name, v, o))
+	return nil
+}
+
+// Clear a cookie. Options should be the same as the ones used to set the cookie, except Expires and MaxAge.
+func (res *Response) ClearCookie(name string, options ...CookieOptions) {
+	o := CookieOptions{}
+	if len(options) > 0 {
+		o = options[0]
+	}
+
+	o.Expires = time.Unix(0, 0)
+	o.MaxAge = 0
+	if o.Path == "" {
+		o.Path = "/"
+	}
+
+	res.Append("Set-Cookie", serializeCookie(name, "", o))
+}
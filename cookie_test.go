@@ -0,0 +1,82 @@
+package expressgo
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignCookie(t *testing.T) {
+	// the vector of cookie-signature
+	const signed = "hello.DGDUkGlIkCzPz+C0B064FNgHdEjox7ch8tOBGslZ5QI"
+
+	if s := SignCookie("hello", "tobiiscool"); s != signed {
+		t.Errorf("expected %q, got %q", signed, s)
+	}
+
+	tests := []struct {
+		signed  string
+		secrets []string
+		value   string
+		ok      bool
+	}{
+		{signed, []string{"tobiiscool"}, "hello", true},
+		// rotated secrets are tried in order
+		{signed, []string{"new", "tobiiscool"}, "hello", true},
+		{signed, []string{"luna"}, "", false},
+		{signed, nil, "", false},
+		{"hello.DGDUkGlIkCzPz+C0B064FNgHdEjox7ch8tOBGslZ5QJ", []string{"tobiiscool"}, "", false},
+		{"hellohello.DGDUkGlIkCzPz+C0B064FNgHdEjox7ch8tOBGslZ5QI", []string{"tobiiscool"}, "", false},
+		{"hello", []string{"tobiiscool"}, "", false},
+		// values could contain dots
+		{SignCookie("a.b.c", "secret"), []string{"secret"}, "a.b.c", true},
+	}
+
+	for _, test := range tests {
+		if value, ok := UnsignCookie(test.signed, test.secrets...); value != test.value || ok != test.ok {
+			t.Errorf("%q %v: expected %q %v, got %q %v", test.signed, test.secrets, test.value, test.ok, value, ok)
+		}
+	}
+}
+
+func TestResponseCookie(t *testing.T) {
+	app := CreateServer()
+	app.Get("/cookie", func(req *Request, res *Response, next *Next) {
+		req.Secret = "tobiiscool"
+		res.Cookie("name", "tobi ferret", CookieOptions{HttpOnly: true, SameSite: "lax"})
+		res.Cookie("signed", "hello", CookieOptions{Signed: true})
+		res.Cookie("json", map[string]int{"a": 1}, CookieOptions{MaxAge: time.Hour})
+		res.ClearCookie("old", CookieOptions{Path: "/admin"})
+		res.Send("ok")
+	})
+	app.Get("/unsigned", func(req *Request, res *Response, next *Next) {
+		if err := res.Cookie("signed", "hello", CookieOptions{Signed: true}); err != ErrCookieSecret {
+			t.Errorf("expected ErrCookieSecret without a secret, got %v", err)
+		}
+		res.Send("ok")
+	})
+
+	resp, _ := serveResponse(app, http.MethodGet, "/cookie")
+	cookies := resp.Header.Values("Set-Cookie")
+	if len(cookies) != 4 {
+		t.Fatalf("expected 4 cookies, got %q", cookies)
+	}
+
+	expected := []string{
+		"name=tobi%20ferret; Path=/; HttpOnly; SameSite=Lax",
+		// the same as the cookie set by Express with the secret
+		"signed=s%3Ahello.DGDUkGlIkCzPz%2BC0B064FNgHdEjox7ch8tOBGslZ5QI; Path=/",
+		"",
+		"old=; Path=/admin; Expires=Thu, 01 Jan 1970 00:00:00 GMT",
+	}
+	for i, cookie := range expected {
+		if cookie != "" && cookies[i] != cookie {
+			t.Errorf("expected %q, got %q", cookie, cookies[i])
+		}
+	}
+	if want := "json=j%3A%7B%22a%22%3A1%7D; Max-Age=3600; Path=/; Expires="; len(cookies[2]) < len(want) || cookies[2][:len(want)] != want {
+		t.Errorf("expected %q..., got %q", want, cookies[2])
+	}
+
+	serveResponse(app, http.MethodGet, "/unsigned")
+}
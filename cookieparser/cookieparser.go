@@ -0,0 +1,133 @@
+package cookieparser
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/Eandalf/expressgo"
+)
+
+type CookieParserConfig struct {
+	// string or []string, the first one is used for signing and all are tried for unsigning
+	Secret      any
+	secretSlice []string
+	// decode the value, defaults to the same as decodeURIComponent in JavaScript
+	Decode func(string) (string, error)
+}
+
+// Parse the Cookie header into a map. The first value of a name takes precedence.
+//
+// Values failed to be decoded are kept as they are.
+func Parse(header string, decode func(string) (string, error)) map[string]string {
+	if decode == nil {
+		decode = url.PathUnescape
+	}
+
+	cookies := map[string]string{}
+	for _, pair := range strings.Split(header, ";") {
+		name, value, found := strings.Cut(pair, "=")
+		if !found {
+			continue
+		}
+
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		// only assign once
+		if _, ok := cookies[name]; ok {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		// remove quotes
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			value = value[1 : len(value)-1]
+		}
+
+		if decoded, err := decode(value); err == nil {
+			value = decoded
+		}
+
+		cookies[name] = value
+	}
+
+	return cookies
+}
+
+// Move signed cookies, with the "s:" prefix, from cookies to a new map. Cookies with invalid signatures are dropped.
+func signedCookies(cookies map[string]string, secrets []string) map[string]string {
+	signed := map[string]string{}
+	for name, value := range cookies {
+		if !strings.HasPrefix(value, "s:") {
+			continue
+		}
+
+		delete(cookies, name)
+		if v, ok := expressgo.UnsignCookie(value[2:], secrets...); ok {
+			signed[name] = v
+		}
+	}
+
+	return signed
+}
+
+// Strip the "j:" prefix of JSON cookies. Values not being valid JSON are kept as they are.
+func jsonCookies(cookies map[string]string) {
+	for name, value := range cookies {
+		if strings.HasPrefix(value, "j:") && json.Valid([]byte(value[2:])) {
+			cookies[name] = value[2:]
+		}
+	}
+}
+
+func Use(cookieParserConfig ...CookieParserConfig) expressgo.Callback {
+	// the default config
+	config := CookieParserConfig{
+		secretSlice: []string{},
+		Decode:      url.PathUnescape,
+	}
+
+	// merge configs
+	if len(cookieParserConfig) > 0 {
+		userConfig := cookieParserConfig[0]
+
+		if s, ok := userConfig.Secret.(string); ok && s != "" {
+			config.secretSlice = []string{s}
+		} else if ss, ok := userConfig.Secret.([]string); ok && len(ss) > 0 {
+			config.secretSlice = ss
+		}
+		if userConfig.Decode != nil {
+			config.Decode = userConfig.Decode
+		}
+	}
+
+	// create cookie parser middleware
+	parser := func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		// proceed to the next callback
+		next.Next = true
+		next.Route = true
+
+		// cookies are parsed by parsers before
+		if req.Cookies != nil {
+			return
+		}
+
+		if len(config.secretSlice) > 0 {
+			req.Secret = config.secretSlice[0]
+		}
+
+		// cookies might be split into multiple headers in HTTP/2
+		req.Cookies = Parse(strings.Join(req.Native.Header.Values("Cookie"), "; "), config.Decode)
+		req.SignedCookies = map[string]string{}
+
+		if len(config.secretSlice) > 0 {
+			req.SignedCookies = signedCookies(req.Cookies, config.secretSlice)
+			jsonCookies(req.SignedCookies)
+		}
+		jsonCookies(req.Cookies)
+	}
+
+	return parser
+}
@@ -0,0 +1,61 @@
+package cookieparser_test
+
+import (
+	"testing"
+
+	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/cookieparser"
+	"github.com/Eandalf/expressgo/expressgotest"
+)
+
+func TestParse(t *testing.T) {
+	cookies := cookieparser.Parse(`a=1; b="quoted"; c=tobi%20ferret; a=2; bad%=%zz; =empty; flag`, nil)
+
+	expected := map[string]string{"a": "1", "b": "quoted", "c": "tobi ferret", "bad%": "%zz"}
+	if len(cookies) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, cookies)
+	}
+	for name, value := range expected {
+		if cookies[name] != value {
+			t.Errorf("%s: expected %q, got %q", name, value, cookies[name])
+		}
+	}
+}
+
+func TestUse(t *testing.T) {
+	app := expressgo.CreateServer()
+	app.UseGlobal(cookieparser.Use(cookieparser.CookieParserConfig{Secret: []string{"new", "tobiiscool"}}))
+	app.Get("/cookies", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Json(map[string]any{
+			"cookies": req.Cookies,
+			"signed":  req.SignedCookies,
+			"secret":  req.Secret,
+		})
+	})
+
+	agent := expressgotest.New(&app)
+
+	// cookies signed by Express with the secret "tobiiscool"
+	agent.Get("/cookies").
+		Set("Cookie", "name=tobi; signed=s%3Ahello.DGDUkGlIkCzPz%2BC0B064FNgHdEjox7ch8tOBGslZ5QI; forged=s%3Ahello.AAAA; json=j%3A%7B%22a%22%3A1%7D").
+		Expect(t).
+		Status(200).
+		Json("cookies.name", "tobi").
+		Json("cookies.json", `{"a":1}`).
+		Json("signed.signed", "hello").
+		Json("secret", "new")
+
+	var body struct {
+		Cookies map[string]string
+		Signed  map[string]string
+	}
+	agent.Get("/cookies").
+		Set("Cookie", "signed=s%3Ahello.DGDUkGlIkCzPz%2BC0B064FNgHdEjox7ch8tOBGslZ5QI; forged=s%3Ahello.AAAA").
+		Expect(t).
+		Unmarshal(&body)
+
+	// signed cookies are moved out of req.Cookies, and forged ones are dropped
+	if len(body.Cookies) != 0 || len(body.Signed) != 1 || body.Signed["signed"] != "hello" {
+		t.Errorf("expected only the valid signed cookie, got %v %v", body.Cookies, body.Signed)
+	}
+}
@@ -1,6 +1,6 @@
 module github.com/Eandalf/expressgo/examples/helloworld
 
-go 1.23.2
+go 1.24
 
 require github.com/Eandalf/expressgo v0.0.0-00010101000000-000000000000
 
@@ -11,3 +11,17 @@ replace github.com/Eandalf/expressgo => ../..
 replace github.com/Eandalf/expressgo/bodyparser => ../../bodyparser
 
 replace github.com/Eandalf/expressgo/cors => ../../cors
+
+replace github.com/Eandalf/expressgo/cookieparser => ../../cookieparser
+
+replace github.com/Eandalf/expressgo/static => ../../static
+
+replace github.com/Eandalf/expressgo/session => ../../session
+
+replace github.com/Eandalf/expressgo/logger => ../../logger
+
+replace github.com/Eandalf/expressgo/mime => ../../mime
+
+replace github.com/Eandalf/expressgo/expressgotest => ../../expressgotest
+
+replace github.com/Eandalf/expressgo/timeout => ../../timeout
@@ -3,10 +3,15 @@ package main
 import (
 	"errors"
 	"fmt"
+	"log"
 
 	"github.com/Eandalf/expressgo"
 	"github.com/Eandalf/expressgo/bodyparser"
+	"github.com/Eandalf/expressgo/cookieparser"
 	"github.com/Eandalf/expressgo/cors"
+	"github.com/Eandalf/expressgo/logger"
+	"github.com/Eandalf/expressgo/session"
+	"github.com/Eandalf/expressgo/static"
 )
 
 func main() {
@@ -17,10 +22,12 @@ func main() {
 
 	// app.Set("case sensitive routing", true)
 
+	app.UseGlobal(logger.Use("dev"))
+
 	app.UseGlobal(cors.Use())
 
 	app.UseGlobal(func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
-		req.Params["global"] = "global"
+		req.Locals["global"] = "global"
 		next.Route = true
 	})
 
@@ -59,6 +66,47 @@ func main() {
 		res.Append("Access-Control-Allow-Origin", "google.com")
 	})
 
+	app.Get("/test/res/write", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(res, "chunk %d\n", i)
+			res.Flush()
+		}
+		res.End()
+	})
+
+	app.Get("/test/res/json", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		next.Err = res.Jsonp(map[string]string{"test": "test_string"})
+	})
+
+	app.Get("/test/cookie", cookieparser.Use(cookieparser.CookieParserConfig{Secret: "secret"}), func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Cookie("test", "test_string")
+		next.Err = res.Cookie("signed", "test_string", expressgo.CookieOptions{Signed: true, HttpOnly: true})
+		res.Send(req.Cookies["test"] + " " + req.SignedCookies["signed"])
+	})
+
+	app.Post("/test/body/multipart", bodyparser.Multipart().Any(), func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		output := ""
+		if f, ok := req.Body.(expressgo.BodyFormUrlEncoded); ok {
+			for k, v := range f {
+				output += fmt.Sprintf("%s: %s<br />", k, v)
+			}
+		}
+		for k, files := range req.Files {
+			for _, file := range files {
+				output += fmt.Sprintf("%s: %s (%d bytes)<br />", k, file.OriginalName, file.Size)
+			}
+		}
+		res.Send(output)
+	})
+
+	app.Get("/test/session", session.Use(session.SessionConfig{Secret: "secret"}), func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		views, _ := req.Session.Get("views").(float64)
+		req.Session.Set("views", views+1)
+		res.Send(fmt.Sprintf("views: %v", views+1))
+	})
+
+	app.Get("/test/static/*path", static.Serve("."))
+
 	app.Get("127.0.0.1/test/host", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
 		res.Send("Hello from 127.0.0.1/test/host")
 	})
@@ -166,13 +214,21 @@ func main() {
 		}
 	})
 
+	router := expressgo.CreateRouter()
+
+	router.Get("/:id", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Send(req.BaseUrl + " " + req.Params["id"])
+	})
+
+	app.Use("/test/router", router)
+
 	app.UseError(
 		"/test/error",
 		func(err error, req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
-			req.Params["error0"] = err.Error()
+			req.Locals["error0"] = err.Error()
 			next.Err = errors.New("raised error in /test/error 1st error handler")
 		}, func(err error, req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
-			req.Params["error1"] = err.Error()
+			req.Locals["error1"] = err.Error()
 			next.Err = errors.New("raised error in /test/error 2nd error handler")
 		},
 	)
@@ -181,5 +237,13 @@ func main() {
 		res.Send("global error: " + err.Error())
 	})
 
-	app.Listen(8080)
+	app.OnShutdown(func() {
+		log.Println("helloworld is stopped")
+	})
+
+	server, err := app.Listen(8080)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	server.Wait()
 }
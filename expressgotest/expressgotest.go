@@ -0,0 +1,334 @@
+package expressgotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Eandalf/expressgo/mime"
+)
+
+// An agent sending requests to a handler in-process, e.g., an app of expressgo, cookies set by responses are kept and sent with later requests.
+type Agent struct {
+	handler http.Handler
+	jar     http.CookieJar
+}
+
+// Create an agent for the handler, e.g., expressgotest.New(&app).
+func New(handler http.Handler) *Agent {
+	// cookiejar.New never fails without options
+	jar, _ := cookiejar.New(nil)
+	return &Agent{handler: handler, jar: jar}
+}
+
+// A request to be sent by an agent.
+type Request struct {
+	agent  *Agent
+	method string
+	target string
+	header http.Header
+	query  url.Values
+	body   []byte
+	err    error
+}
+
+// Create a request with the method to the target, which is a path, e.g., "/users?id=1", or an absolute URL, e.g., "https://example.com/users".
+func (a *Agent) Request(method string, target string) *Request {
+	return &Request{
+		agent:  a,
+		method: method,
+		target: target,
+		header: http.Header{},
+		query:  url.Values{},
+	}
+}
+
+func (a *Agent) Get(target string) *Request {
+	return a.Request(http.MethodGet, target)
+}
+
+func (a *Agent) Head(target string) *Request {
+	return a.Request(http.MethodHead, target)
+}
+
+func (a *Agent) Post(target string) *Request {
+	return a.Request(http.MethodPost, target)
+}
+
+func (a *Agent) Put(target string) *Request {
+	return a.Request(http.MethodPut, target)
+}
+
+func (a *Agent) Patch(target string) *Request {
+	return a.Request(http.MethodPatch, target)
+}
+
+func (a *Agent) Delete(target string) *Request {
+	return a.Request(http.MethodDelete, target)
+}
+
+func (a *Agent) Options(target string) *Request {
+	return a.Request(http.MethodOptions, target)
+}
+
+// Set a request header, field: value.
+func (r *Request) Set(field string, value string) *Request {
+	r.header.Set(field, value)
+	return r
+}
+
+// Set Content-Type to a mime type or a shorthand, e.g., "json", "html", or "form" for application/x-www-form-urlencoded.
+func (r *Request) Type(t string) *Request {
+	if t == "form" {
+		t = "urlencoded"
+	}
+	if normalized := mime.Normalize(t); normalized != "" {
+		t = normalized
+	}
+
+	r.header.Set("Content-Type", t)
+	return r
+}
+
+// Add a value to the query string, which is merged with the query in the target.
+func (r *Request) Query(key string, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Set the request body.
+//
+// A string or []byte is sent as it is, url.Values is sent as a urlencoded form, and other values are encoded as JSON. Content-Type is set if it is not set.
+func (r *Request) Send(body any) *Request {
+	contentType := ""
+
+	switch b := body.(type) {
+	case string:
+		r.body = []byte(b)
+		contentType = "text/plain; charset=utf-8"
+	case []byte:
+		r.body = b
+		contentType = "application/octet-stream"
+	case url.Values:
+		r.body = []byte(b.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		r.body, r.err = json.Marshal(b)
+		contentType = "application/json"
+	}
+
+	if r.header.Get("Content-Type") == "" {
+		r.header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+// Send the request, and return the response for expectations, which report failures to t.
+func (r *Request) Expect(t testing.TB) *Response {
+	t.Helper()
+
+	if r.err != nil {
+		t.Fatalf("%s %s: %v", r.method, r.target, r.err)
+	}
+
+	req := httptest.NewRequest(r.method, r.target, bytes.NewReader(r.body))
+	for field, values := range r.header {
+		req.Header[field] = values
+	}
+	if len(r.query) > 0 {
+		query := req.URL.Query()
+		for key, values := range r.query {
+			query[key] = append(query[key], values...)
+		}
+		req.URL.RawQuery = query.Encode()
+		req.RequestURI = req.URL.RequestURI()
+	}
+
+	// cookies are kept by the URL with the scheme and the host, and the path could be rewritten by the handler
+	u := *req.URL
+	u.Scheme = "http"
+	if req.TLS != nil {
+		u.Scheme = "https"
+	}
+	u.Host = req.Host
+
+	// send cookies kept by the agent
+	for _, cookie := range r.agent.jar.Cookies(&u) {
+		req.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	r.agent.handler.ServeHTTP(w, req)
+	native := w.Result()
+
+	// keep cookies set by the response
+	r.agent.jar.SetCookies(&u, native.Cookies())
+
+	text, _ := io.ReadAll(native.Body)
+	native.Body.Close()
+
+	return &Response{
+		Native: native,
+		Text:   string(text),
+		t:      t,
+		name:   r.method + " " + r.target,
+	}
+}
+
+// A response of a request, with fluent expectations.
+type Response struct {
+	Native *http.Response
+	// the response body
+	Text string
+	t    testing.TB
+	// the method and the target of the request, for messages of failures
+	name string
+}
+
+// Check if the actual value matches the expected one, which could be a string or a *regexp.Regexp.
+func matches(actual string, expected any) bool {
+	switch e := expected.(type) {
+	case *regexp.Regexp:
+		return e.MatchString(actual)
+	case string:
+		return actual == e
+	}
+
+	return actual == fmt.Sprint(expected)
+}
+
+// Expect the status code.
+func (res *Response) Status(statusCode int) *Response {
+	res.t.Helper()
+
+	if res.Native.StatusCode != statusCode {
+		res.t.Errorf("%s: expected status %d, got %d", res.name, statusCode, res.Native.StatusCode)
+	}
+	return res
+}
+
+// Expect a response header to equal a string or match a *regexp.Regexp.
+func (res *Response) Header(field string, expected any) *Response {
+	res.t.Helper()
+
+	values := res.Native.Header.Values(field)
+	if len(values) == 0 {
+		res.t.Errorf("%s: expected header %s, which is not set", res.name, field)
+		return res
+	}
+
+	if actual := strings.Join(values, ", "); !matches(actual, expected) {
+		res.t.Errorf("%s: expected header %s to be %v, got %q", res.name, field, expected, actual)
+	}
+	return res
+}
+
+// Expect a response header not to be set.
+func (res *Response) NoHeader(field string) *Response {
+	res.t.Helper()
+
+	if values := res.Native.Header.Values(field); len(values) > 0 {
+		res.t.Errorf("%s: expected header %s not to be set, got %q", res.name, field, strings.Join(values, ", "))
+	}
+	return res
+}
+
+// Expect the body to equal a string or match a *regexp.Regexp.
+func (res *Response) Body(expected any) *Response {
+	res.t.Helper()
+
+	if !matches(res.Text, expected) {
+		res.t.Errorf("%s: expected body to be %v, got %q", res.name, expected, res.Text)
+	}
+	return res
+}
+
+// Expect the value at the path in the JSON body to equal the expected value, or match a *regexp.Regexp if the value is a string.
+//
+// The path is dot-separated keys and indexes, e.g., "user.name" and "items.0.id", "" for the whole body. The expected value is compared after being encoded and decoded as JSON, so 1 equals 1.0.
+func (res *Response) Json(path string, expected any) *Response {
+	res.t.Helper()
+
+	var body any
+	if err := json.Unmarshal([]byte(res.Text), &body); err != nil {
+		res.t.Errorf("%s: expected a JSON body, got %q", res.name, res.Text)
+		return res
+	}
+
+	actual, ok := lookupJson(body, path)
+	if !ok {
+		res.t.Errorf("%s: expected JSON path %q, which is not found in %s", res.name, path, res.Text)
+		return res
+	}
+
+	if re, ok := expected.(*regexp.Regexp); ok {
+		if s, isString := actual.(string); !isString || !re.MatchString(s) {
+			res.t.Errorf("%s: expected JSON path %q to match %v, got %#v", res.name, path, re, actual)
+		}
+		return res
+	}
+
+	// normalize the expected value to types decoded from JSON
+	var normalized any
+	encoded, err := json.Marshal(expected)
+	if err == nil {
+		err = json.Unmarshal(encoded, &normalized)
+	}
+	if err != nil {
+		res.t.Errorf("%s: expected value of JSON path %q could not be encoded as JSON: %v", res.name, path, err)
+		return res
+	}
+
+	if !reflect.DeepEqual(actual, normalized) {
+		res.t.Errorf("%s: expected JSON path %q to be %#v, got %#v", res.name, path, normalized, actual)
+	}
+	return res
+}
+
+// Get the value at the dot-separated path in a decoded JSON value.
+func lookupJson(value any, path string) (any, bool) {
+	if path == "" {
+		return value, true
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]any:
+			child, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// Decode the JSON body into v, failing the test if it could not be decoded.
+func (res *Response) Unmarshal(v any) *Response {
+	res.t.Helper()
+
+	if err := json.Unmarshal([]byte(res.Text), v); err != nil {
+		res.t.Errorf("%s: body could not be decoded as JSON: %v", res.name, err)
+	}
+	return res
+}
@@ -0,0 +1,68 @@
+package expressgotest_test
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/bodyparser"
+	"github.com/Eandalf/expressgo/expressgotest"
+)
+
+func TestAgent(t *testing.T) {
+	app := expressgo.CreateServer()
+	app.UseGlobal(bodyparser.Json(), bodyparser.Urlencoded())
+
+	app.Get("/user/:id", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Json(map[string]any{
+			"id":    req.Params["id"],
+			"tags":  []string{"a", req.Query["tag"]},
+			"admin": req.Get("X-Admin") == "true",
+		})
+	})
+	app.Post("/echo", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		switch body := req.Body.(type) {
+		case *expressgo.BodyJsonBase:
+			res.Json(body)
+		case expressgo.BodyFormUrlEncoded:
+			res.Send(body["name"])
+		}
+	})
+	app.Get("/login", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Cookie("user", "tobi")
+		res.Send("ok")
+	})
+	app.Get("/whoami", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		cookie, err := req.Native.Cookie("user")
+		if err != nil {
+			res.Status(401).Send("anonymous")
+			return
+		}
+		res.Send(cookie.Value)
+	})
+
+	agent := expressgotest.New(&app)
+
+	agent.Get("/user/1").Query("tag", "b").Set("X-Admin", "true").Expect(t).
+		Status(200).
+		Header("Content-Type", regexp.MustCompile("json")).
+		Json("id", "1").
+		Json("tags.1", "b").
+		Json("admin", true)
+
+	agent.Post("/echo").Send(map[string]any{"count": 1, "items": []int{1, 2}}).Expect(t).
+		Status(200).
+		Json("count", 1).
+		Json("items", []int{1, 2})
+
+	agent.Post("/echo").Send(url.Values{"name": {"tobi"}}).Expect(t).
+		Status(200).
+		Body("tobi")
+
+	agent.Get("/whoami").Expect(t).Status(401)
+	agent.Get("/login").Expect(t).Status(200).Header("Set-Cookie", regexp.MustCompile("^user=tobi"))
+	agent.Get("/whoami").Expect(t).Status(200).Body("tobi")
+
+	agent.Get("/missing").Expect(t).Status(404).NoHeader("Allow")
+}
@@ -0,0 +1,107 @@
+package expressgo
+
+import (
+	"html"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Send the default response for 404 or 405, JSON if the client prefers it to HTML.
+func sendFallback(req *Request, res *Response, statusCode int) {
+	message := "Cannot " + req.Native.Method + " " + req.originalPath
+
+	res.Status(statusCode)
+	res.Set("X-Content-Type-Options", "nosniff")
+
+	if req.Accepts("html", "json") == "json" {
+		res.Json(map[string]any{"status": statusCode, "message": message})
+		return
+	}
+
+	res.Set("Content-Type", "text/html; charset=utf-8")
+	res.Set("Content-Security-Policy", "default-src 'none'")
+	res.Send("<!DOCTYPE html>\n<html lang=\"en\">\n<head>\n<meta charset=\"utf-8\">\n<title>Error</title>\n</head>\n<body>\n<pre>" + html.EscapeString(message) + "</pre>\n</body>\n</html>\n")
+}
+
+func notFound(req *Request, res *Response, next *Next) {
+	sendFallback(req, res, http.StatusNotFound)
+}
+
+func methodNotAllowed(req *Request, res *Response, next *Next) {
+	sendFallback(req, res, http.StatusMethodNotAllowed)
+}
+
+// Answer OPTIONS requests with methods in the Allow header.
+func options(req *Request, res *Response, next *Next) {
+	res.Send(res.Get("Allow"))
+}
+
+// Check if two lists of callbacks are the same list, rather than lists of the same callbacks.
+func isSameList(a []Callback, b []Callback) bool {
+	return len(a) > 0 && len(b) > 0 && &a[0] == &b[0]
+}
+
+// Get methods of routes answering the request, in the order of allMethods.
+//
+// Routes with middlewares only, e.g., of app.Use, or with params not matching their lists are not counted.
+func (h *Handler) allowedMethods(r *http.Request) []string {
+	h.app.mu.RLock()
+	autoHead := h.app.config.autoHead
+	h.app.mu.RUnlock()
+
+	methods := []string{}
+	for _, method := range allMethods {
+		// GET routes also answer HEAD requests with auto head
+		if h.isAnsweredBy(r, method) || (autoHead && method == http.MethodHead && h.isAnsweredBy(r, http.MethodGet)) {
+			methods = append(methods, method)
+		}
+	}
+
+	return methods
+}
+
+// Serve a request matching no routes with the callbacks set by app.NotFound or app.MethodNotAllowed, or auto options, after global callbacks.
+//
+// Methods in allowed are the ones with routes matching the path, the request is served as not found if there are none.
+//
+// The base is the routing state of a route with middlewares only, whose callbacks are run instead of global callbacks, nil if no route matches.
+func (h *Handler) serveFallback(w http.ResponseWriter, r *http.Request, allowed []string, base *routing) {
+	h.app.mu.RLock()
+	if base == nil {
+		base = &routing{callbacks: *h.app.globalCallbacks}
+	}
+	// global error handlers are left out here, and run after the fallbacks instead
+	callbacks := slices.DeleteFunc(slices.Clone(base.callbacks), func(l []Callback) bool {
+		return slices.ContainsFunc(*h.app.globalErrorCallbacks, func(e []Callback) bool {
+			return isSameList(l, e)
+		})
+	})
+	fallbacks := *h.app.notFoundCallbacks
+	fallback := notFound
+	if len(allowed) > 0 {
+		fallbacks = *h.app.methodNotAllowedCallbacks
+		fallback = methodNotAllowed
+	}
+	// OPTIONS requests for paths with routes are answered with auto options
+	if len(allowed) > 0 && r.Method == http.MethodOptions && h.app.config.autoOptions {
+		fallbacks = nil
+		fallback = options
+	}
+	if len(fallbacks) > 0 {
+		callbacks = append(callbacks, fallbacks...)
+	} else {
+		callbacks = append(callbacks, wrapCallbacks([]Callback{fallback}))
+	}
+	// errors from global callbacks and fallbacks are handled by global error handlers, which are only run with errors
+	callbacks = append(callbacks, *h.app.globalErrorCallbacks...)
+	h.app.mu.RUnlock()
+
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+	}
+
+	base.callbacks = callbacks
+	u := &UserHandler{app: h.app}
+	u.serve(w, r, base)
+}
@@ -0,0 +1,236 @@
+package expressgo
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNotFound(t *testing.T) {
+	app := CreateServer()
+	app.Get("/users", func(req *Request, res *Response, next *Next) {
+		res.Send("users")
+	})
+
+	// the default response is HTML
+	resp, body := serveResponse(app, http.MethodGet, "/missing")
+	if resp.StatusCode != http.StatusNotFound || !strings.Contains(body, "<pre>Cannot GET /missing</pre>") || resp.Header.Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("expected the default 404, got %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	app.NotFound(func(req *Request, res *Response, next *Next) {
+		res.Status(http.StatusNotFound).Send("custom " + req.OriginalUrl)
+	})
+	if status, body := serve(app, http.MethodGet, "/missing"); status != http.StatusNotFound || body != "custom /missing" {
+		t.Errorf("expected the custom 404, got %d %q", status, body)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	app := CreateServer()
+	app.Get("/users", func(req *Request, res *Response, next *Next) {
+		res.Send("users")
+	})
+	app.Put("/users", func(req *Request, res *Response, next *Next) {
+		res.Send("put")
+	})
+
+	resp, _ := serveResponse(app, http.MethodPost, "/users")
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD, PUT" {
+		t.Errorf("expected 405 with the Allow header, got %d %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+
+	app.MethodNotAllowed(func(req *Request, res *Response, next *Next) {
+		res.Status(http.StatusMethodNotAllowed).Send("allowed: " + res.Get("Allow"))
+	})
+	if status, body := serve(app, http.MethodDelete, "/users"); status != http.StatusMethodNotAllowed || body != "allowed: GET, HEAD, PUT" {
+		t.Errorf("expected the custom 405, got %d %q", status, body)
+	}
+}
+
+func TestAllowedMethodsWithoutRunningRoutes(t *testing.T) {
+	app := CreateServer()
+
+	runs := 0
+	count := func(req *Request, res *Response, next *Next) {
+		runs++
+		res.Send(req.Params["from"])
+	}
+	app.Get("/files/:from-:to", count)
+	app.Put("/files/:name", count)
+	app.Delete("/files/:id(\\d+)", count)
+
+	// lists with params not matching are not counted
+	resp, _ := serveResponse(app, http.MethodPost, "/files/a-b")
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD, PUT" {
+		t.Errorf("expected 405 with GET, HEAD, PUT, got %d %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+	resp, _ = serveResponse(app, http.MethodOptions, "/files/12")
+	if resp.Header.Get("Allow") != "PUT, DELETE" {
+		t.Errorf("expected PUT, DELETE, got %q", resp.Header.Get("Allow"))
+	}
+	if runs != 0 {
+		t.Errorf("expected no routes run for the Allow header, got %d runs", runs)
+	}
+}
+
+// app.All registers a route answering all methods, not middlewares as app.Use does.
+func TestAllIsRoute(t *testing.T) {
+	app := CreateServer()
+	app.All("/all", func(req *Request, res *Response, next *Next) {
+		res.Set("X-All", "true")
+		next.Route = true
+	})
+	app.Use("/use", func(req *Request, res *Response, next *Next) {
+		next.Route = true
+	})
+
+	// a route passing requests on with nothing after it still answers them
+	resp, body := serveResponse(app, http.MethodDelete, "/all")
+	if resp.StatusCode != http.StatusOK || body != "" || resp.Header.Get("X-All") != "true" {
+		t.Errorf("expected the route to answer, got %d %q", resp.StatusCode, body)
+	}
+	resp, _ = serveResponse(app, http.MethodOptions, "/all")
+	if resp.Header.Get("X-All") != "true" || resp.Header.Get("Allow") != "" {
+		t.Errorf("expected OPTIONS answered by the route, got Allow %q", resp.Header.Get("Allow"))
+	}
+	if status, _ := serve(app, http.MethodGet, "/use"); status != http.StatusNotFound {
+		t.Errorf("expected 404 for middlewares only, got %d", status)
+	}
+}
+
+func TestFallbackOrdering(t *testing.T) {
+	app := CreateServer()
+
+	order := []string{}
+	app.UseGlobal(func(req *Request, res *Response, next *Next) {
+		order = append(order, "global")
+		if req.Query["fail"] == "global" {
+			next.Err = errors.New("global failed")
+		}
+		next.Route = true
+	})
+	app.UseGlobalError(func(err error, req *Request, res *Response, next *Next) {
+		order = append(order, "log")
+		next.Err = err
+	})
+	app.UseGlobalError(func(err error, req *Request, res *Response, next *Next) {
+		order = append(order, "error handler")
+		res.Status(http.StatusInternalServerError).Send(err.Error())
+	})
+	// error handlers registered before a route do not stop it
+	app.Get("/users", func(req *Request, res *Response, next *Next) {
+		order = append(order, "route")
+		res.Send("users")
+	})
+	app.NotFound(func(req *Request, res *Response, next *Next) {
+		order = append(order, "not found")
+		next.Err = errors.New("not found")
+	})
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+		order  string
+	}{
+		{"/users", http.StatusOK, "users", "global,route"},
+		// global error handlers are run once after the fallbacks
+		{"/missing", http.StatusInternalServerError, "not found", "global,not found,log,error handler"},
+		{"/missing?fail=global", http.StatusInternalServerError, "global failed", "global,log,error handler"},
+	}
+
+	for _, test := range tests {
+		order = order[:0]
+		if status, body := serve(app, http.MethodGet, test.target); status != test.status || body != test.body || strings.Join(order, ",") != test.order {
+			t.Errorf("%s: expected %d %q in order %q, got %d %q in order %q", test.target, test.status, test.body, test.order, status, body, strings.Join(order, ","))
+		}
+	}
+
+	// global error handlers passing errors on are not run twice
+	app = CreateServer()
+	logged := 0
+	app.UseGlobal(func(req *Request, res *Response, next *Next) {
+		next.Err = errors.New("global failed")
+	})
+	app.UseGlobalError(func(err error, req *Request, res *Response, next *Next) {
+		logged += 1
+		next.Err = err
+	})
+	serve(app, http.MethodGet, "/missing")
+	if logged != 1 {
+		t.Errorf("expected the error logged once, got %d", logged)
+	}
+}
+
+func TestAutoHeadAndOptions(t *testing.T) {
+	app := CreateServer()
+	app.Use("/users", func(req *Request, res *Response, next *Next) {
+		res.Set("X-Middleware", req.Native.Method)
+		next.Route = true
+	})
+	app.Get("/users", func(req *Request, res *Response, next *Next) {
+		res.Set("X-Route", "get")
+		res.Send("users")
+	})
+	app.Post("/users", func(req *Request, res *Response, next *Next) {
+		res.Send("created")
+	})
+	app.Get("/books", func(req *Request, res *Response, next *Next) {
+		res.Send("books")
+	})
+	app.Options("/books", func(req *Request, res *Response, next *Next) {
+		res.Send("custom options")
+	})
+	app.All("/any", func(req *Request, res *Response, next *Next) {
+		res.Send("any " + req.Native.Method)
+	})
+	app.Use("/middleware", func(req *Request, res *Response, next *Next) {
+		res.Set("X-Middleware", "true")
+		next.Route = true
+	})
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		status  int
+		body    string
+		headers map[string]string
+	}{
+		// HEAD requests are answered by GET routes without the body, after middlewares
+		{"head", http.MethodHead, "/users", http.StatusOK, "", map[string]string{"X-Middleware": "HEAD", "X-Route": "get", "Content-Length": "5"}},
+		// OPTIONS requests are answered with the Allow header, after middlewares
+		{"options with use", http.MethodOptions, "/users", http.StatusOK, "GET, HEAD, POST", map[string]string{"Allow": "GET, HEAD, POST", "X-Middleware": "OPTIONS"}},
+		{"options route", http.MethodOptions, "/books", http.StatusOK, "custom options", nil},
+		{"all", http.MethodOptions, "/any", http.StatusOK, "any OPTIONS", nil},
+		// methods with middlewares only are not allowed
+		{"method not allowed", http.MethodDelete, "/users", http.StatusMethodNotAllowed, "", map[string]string{"Allow": "GET, HEAD, POST", "X-Middleware": "DELETE"}},
+		// paths with middlewares only are not found
+		{"middlewares only", http.MethodGet, "/middleware", http.StatusNotFound, "", map[string]string{"X-Middleware": "true"}},
+		{"options of middlewares only", http.MethodOptions, "/middleware", http.StatusNotFound, "", nil},
+	}
+
+	for _, test := range tests {
+		resp, body := serveResponse(app, test.method, test.target)
+		if resp.StatusCode != test.status || (test.body != "" && body != test.body) || (test.method == http.MethodHead && body != "") {
+			t.Errorf("%s: expected %d %q, got %d %q", test.name, test.status, test.body, resp.StatusCode, body)
+		}
+		for k, v := range test.headers {
+			if resp.Header.Get(k) != v {
+				t.Errorf("%s: expected %s %q, got %q", test.name, k, v, resp.Header.Get(k))
+			}
+		}
+	}
+
+	// OPTIONS requests without OPTIONS routes get 405 without auto options, as HEAD requests without HEAD routes without auto head
+	app.Set("auto options", false)
+	app.Set("auto head", false)
+	if resp, _ := serveResponse(app, http.MethodOptions, "/users"); resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, POST" {
+		t.Errorf("expected 405 without auto options, got %d %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+	if resp, _ := serveResponse(app, http.MethodHead, "/books"); resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, OPTIONS" {
+		t.Errorf("expected 405 without auto head, got %d %q", resp.StatusCode, resp.Header.Get("Allow"))
+	}
+}
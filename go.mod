@@ -1,5 +1,5 @@
 module github.com/Eandalf/expressgo
 
-go 1.23.2
+go 1.24
 
 require golang.org/x/text v0.20.0
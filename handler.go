@@ -1,9 +1,13 @@
 package expressgo
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -20,6 +24,85 @@ type Handler struct {
 	app *App
 }
 
+// The key of the app answering a HEAD request with a GET route in the context of the request, see Handler.ServeHTTP.
+type autoHeadKey struct{}
+
+// Check if values of params match the list, where required params should have values and constraints should be matched.
+//
+// Params not found in the path are not checked against constraints.
+func (l listInfo) matches(values map[string]string) bool {
+	// a param missing its value, e.g., :to of /:from-:to for /abc, means its separator is missing as well
+	for _, name := range l.required {
+		if values[name] == "" {
+			return false
+		}
+	}
+
+	for name, constraint := range l.constraints {
+		if value, ok := values[name]; ok && !constraint.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Get values of wildcards in the pattern matched by the path, as r.PathValue does for requests served by ServeMux.
+func getPatternValues(pattern string, path string) map[string]string {
+	// the method and the host are left out
+	if _, p, found := strings.Cut(pattern, " "); found {
+		pattern = p
+	}
+	if pos := strings.IndexByte(pattern, '/'); pos >= 0 {
+		pattern = pattern[pos:]
+	}
+
+	values := map[string]string{}
+	segments := strings.Split(path, "/")
+	for i, s := range strings.Split(pattern, "/") {
+		if i >= len(segments) || !strings.HasPrefix(s, "{") || s == "{$}" {
+			continue
+		}
+
+		name := strings.Trim(s, "{}")
+		if name, found := strings.CutSuffix(name, "..."); found {
+			values[name], _ = url.PathUnescape(strings.Join(segments[i:], "/"))
+			break
+		}
+		values[name], _ = url.PathUnescape(segments[i])
+	}
+
+	return values
+}
+
+// Check if a route of the method answers the request, which is false for routes with middlewares only, e.g., of app.Use, or with params not matching their lists.
+//
+// The route is looked up from ServeMux without being run.
+func (h *Handler) isAnsweredBy(r *http.Request, method string) bool {
+	lookup := *r
+	lookup.Method = method
+
+	// ServeMux matches HEAD requests with GET routes, which are not routes of HEAD
+	_, pattern := h.mux.Handler(&lookup)
+	if !strings.HasPrefix(pattern, method+" ") {
+		return false
+	}
+
+	h.app.mu.RLock()
+	lists := h.app.lists[pattern]
+	h.app.mu.RUnlock()
+
+	values := getPatternValues(pattern, r.URL.EscapedPath())
+	pathValue := func(name string) string {
+		return values[name]
+	}
+	originalPath := getOriginalPath(r)
+
+	return slices.ContainsFunc(lists, func(l listInfo) bool {
+		return !l.middleware && l.matches(getParams(pattern, pathValue, originalPath, l.params))
+	})
+}
+
 // For path registration
 
 func (h *Handler) isHostIncluded(path string) bool {
@@ -31,6 +114,10 @@ func (h *Handler) makePrecise(path string) string {
 	return strings.TrimSuffix(path, "/") + "/{$}"
 }
 
+func (h *Handler) isWildcard(path string) bool {
+	return strings.HasSuffix(path, "...}")
+}
+
 func (h *Handler) pathToLower(path string) string {
 	isParam := false
 	output := ""
@@ -103,9 +190,11 @@ func (h *Handler) parseParamZone(currentParams []string, currentSeparators []run
 //
 // Separators: hyphen(-): "0H", dot(.): "0D".
 //
+// Wildcard: the last segment in the form of *name is parsed to {name0S...} for matching the rest of the path.
+//
 // parsePath: the parsed path
 //
-// params: a list of params divided by param zones, for example, /:one-:two/:three -> [["one", "0H", "two"], ["three"]]
+// params: a list of params divided by param zones, for example, /:one-:two/:three/*four -> [["one", "0H", "two"], ["three"], ["four", "0S"]]
 //
 // error: any illegal variable naming or param format found
 func (h *Handler) parseParams(path string) (string, [][]string, error) {
@@ -115,6 +204,21 @@ func (h *Handler) parseParams(path string) (string, [][]string, error) {
 		dot    = '.'
 	)
 
+	// a wildcard should be the last segment
+	if pos := strings.Index(path, "/*"); pos >= 0 {
+		name := path[pos+2:]
+		if name == "" || !h.isValidParamName(name) || len(isValidParamChar.FindAllString(name, -1)) != len(name) {
+			return path, [][]string{}, errors.New("wildcard should be the last segment with a valid name, " + name + " is found")
+		}
+
+		parsedPath, params, err := h.parseParams(path[:pos+1])
+		if err != nil {
+			return path, [][]string{}, err
+		}
+
+		return parsedPath + "{" + name + "0S...}", append(params, []string{name, "0S"}), nil
+	}
+
 	parsedPath := ""
 	// all params
 	params := [][]string{}
@@ -222,23 +326,196 @@ func (h *Handler) parseParams(path string) (string, [][]string, error) {
 	return parsedPath, params, nil
 }
 
-func (h *Handler) register(method string, path string, handler *UserHandler) error {
+// Remove regex constraints of params from the path, e.g., /:id(\d+) -> /:id.
+//
+// Return the following in order, the path without constraints, constraints by param names, error.
+//
+// A value of the param should fully match the constraint, and parentheses in the constraint should be balanced or escaped.
+func (h *Handler) parseConstraints(path string) (string, map[string]*regexp.Regexp, error) {
+	output := ""
+	constraints := map[string]*regexp.Regexp{}
+
+	for pos := 0; pos < len(path); pos++ {
+		if path[pos] != '(' {
+			output += path[pos : pos+1]
+			continue
+		}
+
+		// the constraint belongs to the param right before it
+		start := len(output)
+		for start > 0 && isValidParamChar.MatchString(output[start-1:start]) {
+			start -= 1
+		}
+		if start == len(output) || start == 0 || output[start-1] != ':' {
+			return path, nil, errors.New("regex constraint should follow a path param, " + path[:pos+1] + " is found")
+		}
+		name := output[start:]
+
+		// find the closing parenthesis
+		depth := 0
+		end := -1
+		for i := pos; i < len(path) && end < 0; i++ {
+			switch path[i] {
+			case '\\':
+				i += 1
+			case '(':
+				depth += 1
+			case ')':
+				depth -= 1
+				if depth == 0 {
+					end = i
+				}
+			}
+		}
+		if end < 0 {
+			return path, nil, errors.New("regex constraint of " + name + " is not closed")
+		}
+
+		constraint, err := regexp.Compile("^(?:" + path[pos+1:end] + ")$")
+		if err != nil {
+			return path, nil, errors.New("regex constraint of " + name + " is invalid, " + err.Error())
+		}
+		constraints[name] = constraint
+
+		pos = end
+	}
+
+	return output, constraints, nil
+}
+
+// Expand optional segments in braces to all paths with and without them, e.g., /users{/:id} -> [/users/:id, /users].
+//
+// Optional segments could be nested, e.g., /archive{/:year{/:month}}.
+func (h *Handler) expandOptional(path string) ([]string, error) {
+	start := strings.IndexByte(path, '{')
+	if start < 0 {
+		if strings.IndexByte(path, '}') >= 0 {
+			return []string{}, errors.New("optional segment is not opened with a brace ({), " + path + " is found")
+		}
+		if path == "" {
+			path = "/"
+		}
+		return []string{path}, nil
+	}
+
+	// find the closing brace
+	depth := 0
+	end := -1
+	for i := start; i < len(path) && end < 0; i++ {
+		switch path[i] {
+		case '{':
+			depth += 1
+		case '}':
+			depth -= 1
+			if depth == 0 {
+				end = i
+			}
+		}
+	}
+	if end < 0 {
+		return []string{}, errors.New("optional segment is not closed with a brace (}), " + path + " is found")
+	}
+
+	with, err := h.expandOptional(path[:start] + path[start+1:end] + path[end+1:])
+	if err != nil {
+		return []string{}, err
+	}
+
+	// params within a segment are kept in one route, since trailing params of a segment are already optional, e.g., /:file{.:ext} -> /:file.:ext
+	// the patterns of a segment with and without them would conflict in ServeMux
+	inner := path[start+1 : end]
+	if !strings.HasPrefix(inner, "/") && strings.Contains(inner, ":") {
+		return with, nil
+	}
+
+	without, err := h.expandOptional(path[:start] + path[end+1:])
+	if err != nil {
+		return []string{}, err
+	}
+
+	paths := []string{}
+	for _, p := range append(with, without...) {
+		if !slices.Contains(paths, p) {
+			paths = append(paths, p)
+		}
+	}
+
+	return paths, nil
+}
+
+// Name the params of a parsed path by their positions, e.g., /{one0Htwo}/{three}/{four0S...} -> /{p0}/{p1}/{p2...}.
+//
+// Routes of the same shape share the pattern, e.g., /posts/:id(\d+) and /posts/:slug([a-z]+), which are told apart by constraints of their lists of callbacks.
+//
+// The param zone k of a list of callbacks is matched by {pk}, see getParams.
+func (h *Handler) nameByPosition(path string) string {
+	output := ""
+
+	for k := 0; ; k++ {
+		start := strings.IndexByte(path, '{')
+		if start < 0 {
+			return output + path
+		}
+		end := start + strings.IndexByte(path[start:], '}')
+
+		name := "p" + strconv.Itoa(k)
+		if strings.HasSuffix(path[start:end], "...") {
+			name += "..."
+		}
+
+		output += path[:start] + "{" + name + "}"
+		path = path[end+1:]
+	}
+}
+
+// Get names of params in optional segments, e.g., /:file{.:ext} -> [ext].
+func (h *Handler) parseOptionalParams(path string) []string {
+	names := []string{}
+	depth := 0
+
+	for pos := 0; pos < len(path); pos++ {
+		switch path[pos] {
+		case '{':
+			depth += 1
+		case '}':
+			depth -= 1
+		case ':':
+			if depth == 0 {
+				continue
+			}
+			end := pos + 1
+			for end < len(path) && isValidParamChar.MatchString(path[end:end+1]) {
+				end += 1
+			}
+			names = append(names, path[pos+1:end])
+		}
+	}
+
+	return names
+}
+
+// Parse a path without optional segments to the pattern matched by ServeMux.
+//
+// Return the following in order, pattern, params, error.
+func (h *Handler) parsePattern(method string, path string) (string, [][]string, error) {
 	// apply config options
 	if !h.app.config.allowHost && h.isHostIncluded(path) {
-		return errors.New("path cannot contain host")
+		return "", [][]string{}, errors.New("path cannot contain host")
 	}
 
 	// parse params
 	p, params, err := h.parseParams(path)
 	if err != nil {
-		return err
+		return "", [][]string{}, err
 	}
+	p = h.nameByPosition(p)
 
 	// apply config options
 	if !h.app.config.caseSensitive {
 		p = h.pathToLower(p)
 	}
-	if !h.app.config.coarse {
+	// a wildcard matches the rest of the path, which could not be precise
+	if !h.app.config.coarse && !h.isWildcard(p) {
 		p = h.makePrecise(p)
 	}
 
@@ -247,28 +524,81 @@ func (h *Handler) register(method string, path string, handler *UserHandler) err
 		p = method + " " + p
 	}
 
-	// register params
-	h.app.params[p] = params
+	return p, params, nil
+}
+
+// Register the list of callbacks with the routes formed by the method and the path.
+//
+// A path with optional segments is registered as multiple routes, one for each combination of the segments.
+//
+// Middlewares, e.g., of app.Use, are run on the routes, but routes with middlewares only do not answer requests, see Handler.ServeHTTP.
+//
+// Return the registered routes, which are patterns matched by ServeMux, and any error found in the path.
+func (h *Handler) register(method string, path string, callbacks []Callback, middleware bool) ([]string, error) {
+	h.app.mu.Lock()
+	defer h.app.mu.Unlock()
+
+	path, constraints, err := h.parseConstraints(path)
+	if err != nil {
+		return []string{}, err
+	}
+
+	paths, err := h.expandOptional(path)
+	if err != nil {
+		return []string{}, err
+	}
+	optional := h.parseOptionalParams(path)
+
+	// parse all paths before registering any, so an invalid path registers nothing
+	patterns := []string{}
+	paramsOfPatterns := [][][]string{}
+	for _, path := range paths {
+		p, params, err := h.parsePattern(method, path)
+		if err != nil {
+			return []string{}, err
+		}
 
-	// register callbacks
-	// register the slice of callbacks with the route formed by the method and the path
-	callbacks := handler.callbacks
-	// if the route already exists, push the slice of callbacks to map and not register it to ServeMux
-	if _, ok := h.app.callbacks[p]; ok {
-		h.app.callbacks[p] = append(h.app.callbacks[p], callbacks)
-		return nil
+		patterns = append(patterns, p)
+		paramsOfPatterns = append(paramsOfPatterns, params)
 	}
-	// if global middlewares exist
-	if len(*h.app.globalCallbacks) > 0 {
+
+	for i, p := range patterns {
+		// params and constraints belong to the list of callbacks, since routes of the same shape share the pattern
+		info := listInfo{middleware: middleware, params: paramsOfPatterns[i], constraints: constraints}
+		// params within a segment are optional only in optional segments, e.g., :ext of /:file{.:ext}, wildcards could be empty
+		for _, zone := range info.params {
+			if len(zone) == 2 && zone[1] == "0S" {
+				continue
+			}
+			for j := 0; j < len(zone); j += 2 {
+				if !slices.Contains(optional, zone[j]) {
+					info.required = append(info.required, zone[j])
+				}
+			}
+		}
+
+		// register callbacks
+		// register the slice of callbacks with the route formed by the method and the path
+		// if the route already exists, push the slice of callbacks to map and not register it to ServeMux
+		if _, ok := h.app.callbacks[p]; ok {
+			h.app.callbacks[p] = append(h.app.callbacks[p], callbacks)
+			h.app.lists[p] = append(h.app.lists[p], info)
+			continue
+		}
 		// register existing global middlewares first for first-seen routes
-		h.app.callbacks[p] = append(*h.app.globalCallbacks, callbacks)
-		h.mux.Handle(p, &UserHandler{app: h.app, callbacks: (*h.app.globalCallbacks)[0]})
-	} else {
-		h.app.callbacks[p] = [][]Callback{callbacks}
-		h.mux.Handle(p, handler)
+		// globalCallbacks is cloned, otherwise routes could share and overwrite the same underlying array
+		h.app.callbacks[p] = append(slices.Clone(*h.app.globalCallbacks), callbacks)
+		h.app.lists[p] = make([]listInfo, len(*h.app.globalCallbacks)+1)
+		for i := range *h.app.globalCallbacks {
+			h.app.lists[p][i].middleware = true
+		}
+		h.app.lists[p][len(*h.app.globalCallbacks)] = info
+		h.app.routeStarts[p] = len(*h.app.globalCallbacks)
+		// the handler is stateless, callbacks are looked up with r.Pattern for each request
+		h.mux.Handle(p, &UserHandler{app: h.app})
 	}
 
-	return nil
+	return patterns, nil
 }
 
 // For processing requests
@@ -286,7 +616,25 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// > 6. Related issue: [golang/go#60769](https://github.com/golang/go/issues/60769)
 	//
 	// This check should be removed once the issue above being implemented.
-	if !h.app.config.coarse {
+	h.app.mu.RLock()
+	coarse := h.app.config.coarse
+	caseSensitive := h.app.config.caseSensitive
+	autoHead := h.app.config.autoHead
+	h.app.mu.RUnlock()
+
+	// the path is rewritten on a copy of the request, so the request of the caller is kept as it is, as http.StripPrefix does
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r = r2
+
+	// an empty path, e.g., of a request to the prefix of http.StripPrefix, is the root
+	if r.URL.Path == "" {
+		r.URL.Path = "/"
+	}
+
+	if !coarse {
 		path := r.URL.Path
 		lastChar := path[len(path)-1:]
 		if lastChar != "/" {
@@ -295,9 +643,28 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// for case-insensitive path matching
-	if !h.app.config.caseSensitive {
+	if !caseSensitive {
 		r.URL.Path = strings.ToLower(r.URL.Path)
 	}
 
+	// requests matching no routes are served by fallbacks, instead of plain-text responses of ServeMux
+	_, pattern := h.mux.Handler(r)
+	// ServeMux matches HEAD requests with GET routes, which is disabled without auto head
+	if pattern == "" || (!autoHead && r.Method == http.MethodHead && strings.HasPrefix(pattern, http.MethodGet+" ")) {
+		h.serveFallback(w, r, h.allowedMethods(r), nil)
+		return
+	}
+
+	// HEAD requests matching middlewares only, e.g., of app.Use, are answered with GET routes with auto head
+	if autoHead && r.Method == http.MethodHead && strings.HasPrefix(pattern, http.MethodHead+" ") {
+		if !h.isAnsweredBy(r, http.MethodHead) && h.isAnsweredBy(r, http.MethodGet) {
+			// the method is set back to HEAD by UserHandler.ServeHTTP
+			get := r.Clone(context.WithValue(r.Context(), autoHeadKey{}, h.app))
+			get.Method = http.MethodGet
+			h.mux.ServeHTTP(w, get)
+			return
+		}
+	}
+
 	h.mux.ServeHTTP(w, r)
 }
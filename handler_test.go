@@ -0,0 +1,133 @@
+package expressgo
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// Respond with params in the order of names, e.g., "1,png,".
+func sendParams(names ...string) Callback {
+	return func(req *Request, res *Response, next *Next) {
+		values := []string{}
+		for _, name := range names {
+			values = append(values, req.Params[name])
+		}
+		res.Send(strings.Join(values, ","))
+	}
+}
+
+func TestPathSyntax(t *testing.T) {
+	app := CreateServer()
+
+	if err := app.Get("/users{/:id}", sendParams("id")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Get("/images/:file{.:ext{.:gz}}", sendParams("file", "ext", "gz")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Get("/files/*rest", sendParams("rest")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Get("/posts/:id(\\d+)", sendParams("id")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Get("/archive/:year(\\d{4}){/:month(0[1-9]|1[0-2])}", sendParams("year", "month")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/users", http.StatusOK, ""},
+		{"/users/42", http.StatusOK, "42"},
+		{"/images/cat", http.StatusOK, "cat,,"},
+		{"/images/cat.png", http.StatusOK, "cat,png,"},
+		{"/images/cat.png.gz", http.StatusOK, "cat,png,gz"},
+		{"/files/a/B/c.txt", http.StatusOK, "a/B/c.txt"},
+		{"/posts/42", http.StatusOK, "42"},
+		{"/posts/abc", http.StatusNotFound, ""},
+		{"/archive/2024", http.StatusOK, "2024,"},
+		{"/archive/2024/07", http.StatusOK, "2024,07"},
+		{"/archive/24", http.StatusNotFound, ""},
+		{"/archive/2024/13", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		status, body := serve(app, http.MethodGet, test.target)
+		// bodies of 404 responses are not checked
+		if status != test.status || (status == http.StatusOK && body != test.body) {
+			t.Errorf("%s: expected %d %q, got %d %q", test.target, test.status, test.body, status, body)
+		}
+	}
+}
+
+func TestPathSyntaxErrors(t *testing.T) {
+	app := CreateServer()
+
+	paths := []string{
+		"/users{/:id",
+		"/users/:id}",
+		"/posts/(\\d+)",
+		"/posts/:id(\\d+",
+		"/posts/:id([)",
+		"/files/*rest/edit",
+	}
+	for _, path := range paths {
+		if err := app.Get(path, sendParams()); err == nil {
+			t.Errorf("%s: expected an error", path)
+		}
+	}
+}
+
+func TestSameShapeRoutes(t *testing.T) {
+	app := CreateServer()
+
+	// routes differing only by params and constraints are told apart by the constraints
+	if err := app.Get("/posts/:id(\\d+)", sendParams("id", "slug")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Get("/posts/:slug([a-z]+)", sendParams("id", "slug")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Put("/posts/:id(\\d+)", sendParams("id")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Get("/files/:from-:to", sendParams("from", "to")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Get("/files/:name", sendParams("name")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		target string
+		status int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "/posts/42", http.StatusOK, "42,", ""},
+		{http.MethodGet, "/posts/abc", http.StatusOK, ",abc", ""},
+		{http.MethodGet, "/posts/a1", http.StatusNotFound, "", ""},
+		{http.MethodPut, "/posts/42", http.StatusOK, "42", ""},
+		// methods are allowed by routes whose constraints are matched
+		{http.MethodPut, "/posts/abc", http.StatusMethodNotAllowed, "", "GET, HEAD"},
+		{http.MethodDelete, "/posts/42", http.StatusMethodNotAllowed, "", "GET, HEAD, PUT"},
+		{http.MethodOptions, "/posts/abc", http.StatusOK, "GET, HEAD", "GET, HEAD"},
+		// the first list of callbacks answering the request is run
+		{http.MethodGet, "/files/a-b", http.StatusOK, "a,b", ""},
+		// lists missing required params and their separators are left out
+		{http.MethodGet, "/files/abc", http.StatusOK, "abc", ""},
+		{http.MethodGet, "/files/abc-", http.StatusOK, "abc-", ""},
+	}
+
+	for _, test := range tests {
+		resp, body := serveResponse(app, test.method, test.target)
+		if resp.StatusCode != test.status || (test.body != "" && body != test.body) || resp.Header.Get("Allow") != test.allow {
+			t.Errorf("%s %s: expected %d %q %q, got %d %q %q", test.method, test.target, test.status, test.body, test.allow, resp.StatusCode, body, resp.Header.Get("Allow"))
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package expressgo
+
+import (
+	"context"
+)
+
+// A key-value bag for passing values between callbacks.
+//
+// req.Locals and res.Locals are the same bag scoped to a request, and app.Locals is shared by all requests of the app.
+//
+// Locals are not guarded, app.Locals should only be written before the app serves requests, e.g., before app.Listen.
+type Locals map[string]any
+
+// The key of request locals in the context of a request.
+type localsKey struct{}
+
+// Get the value of the key as T, false if the key is not set or the value is not a T.
+//
+// e.g., user, ok := expressgo.Get[*User](req.Locals, "user")
+func Get[T any](locals Locals, key string) (T, bool) {
+	value, ok := locals[key].(T)
+	return value, ok
+}
+
+// Set the value of the key, e.g., expressgo.Set(req.Locals, "user", user).
+func Set[T any](locals Locals, key string, value T) {
+	locals[key] = value
+}
+
+// Get the request locals from the context of a request, e.g., req.Native.Context(), nil if it is not found.
+//
+// It is for libraries receiving a context.Context from callbacks to read values set on req.Locals.
+func LocalsFrom(ctx context.Context) Locals {
+	locals, _ := ctx.Value(localsKey{}).(Locals)
+	return locals
+}
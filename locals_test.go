@@ -0,0 +1,54 @@
+package expressgo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// A library function reading locals from a context.
+func userFromContext(ctx context.Context) string {
+	user, _ := Get[string](LocalsFrom(ctx), "user")
+	return user
+}
+
+func TestLocals(t *testing.T) {
+	app := CreateServer()
+	app.Locals["title"] = "app"
+
+	app.UseGlobal(func(req *Request, res *Response, next *Next) {
+		Set(req.Locals, "user", "tobi")
+		Set(res.Locals, "visits", 3)
+		next.Route = true
+	})
+
+	app.Get("/locals", func(req *Request, res *Response, next *Next) {
+		visits, ok := Get[int](req.Locals, "visits")
+		_, isString := Get[string](req.Locals, "visits")
+		title, _ := Get[string](app.Locals, "title")
+		res.Send(fmt.Sprintf("%s %s %d %v %v", title, userFromContext(req.Native.Context()), visits, ok, isString))
+	})
+
+	// locals are shared with apps mounted as handlers
+	inner := CreateServer()
+	inner.Get("/*path", func(req *Request, res *Response, next *Next) {
+		user, _ := Get[string](req.Locals, "user")
+		res.Send("inner " + user)
+	})
+	app.UseHandler("/inner", &inner)
+
+	tests := []struct {
+		target string
+		body   string
+	}{
+		{"/locals", "app tobi 3 true false"},
+		{"/inner/x", "inner tobi"},
+	}
+
+	for _, test := range tests {
+		if status, body := serve(app, http.MethodGet, test.target); status != http.StatusOK || body != test.body {
+			t.Errorf("%s: expected 200 %q, got %d %q", test.target, test.body, status, body)
+		}
+	}
+}
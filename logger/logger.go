@@ -0,0 +1,232 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Eandalf/expressgo"
+)
+
+const (
+	FormatCombined = "combined"
+	FormatCommon   = "common"
+	FormatDev      = "dev"
+	FormatShort    = "short"
+	FormatTiny     = "tiny"
+)
+
+// A format function returns the log line for the request and the response, an empty line is not logged.
+type FormatFunc func(req *expressgo.Request, res *expressgo.Response) string
+
+type LoggerConfig struct {
+	// log on requests instead of responses, values of response tokens are "-"
+	Immediate bool
+	// skip logging if it returns true
+	Skip func(req *expressgo.Request, res *expressgo.Response) bool
+	// where log lines are written, defaults to os.Stdout
+	Stream io.Writer
+	// log with the slog.Handler instead of writing to Stream if it is set
+	Handler slog.Handler
+}
+
+// name -> format
+var formats = map[string]FormatFunc{}
+var formatsMu sync.RWMutex
+
+// Define a named format, which could be a format string or a FormatFunc. Predefined formats could be overridden.
+func Format(name string, format any) {
+	f, err := compileAny(format)
+	if err != nil {
+		panic(err)
+	}
+
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	formats[name] = f
+}
+
+var tokenPattern = regexp.MustCompile(`:([-\w]{2,})(?:\[([^\]]+)\])?`)
+
+// Compile a format string with tokens, e.g., ":method :url :status", into a FormatFunc.
+//
+// Tokens are resolved when lines are logged, so tokens could be defined after compiling. Unknown tokens are kept as they are.
+func Compile(format string) FormatFunc {
+	return func(req *expressgo.Request, res *expressgo.Response) string {
+		return tokenPattern.ReplaceAllStringFunc(format, func(match string) string {
+			groups := tokenPattern.FindStringSubmatch(match)
+
+			token, ok := getToken(groups[1])
+			if !ok {
+				return match
+			}
+
+			if value := token(req, res, groups[2]); value != "" {
+				return value
+			}
+			return "-"
+		})
+	}
+}
+
+// Get a FormatFunc from a format name, a format string, or a FormatFunc.
+func compileAny(format any) (FormatFunc, error) {
+	switch f := format.(type) {
+	case FormatFunc:
+		return f, nil
+	case func(req *expressgo.Request, res *expressgo.Response) string:
+		return f, nil
+	case string:
+		formatsMu.RLock()
+		named, ok := formats[f]
+		formatsMu.RUnlock()
+
+		if ok {
+			return named, nil
+		}
+		return Compile(f), nil
+	}
+
+	return nil, errors.New("format should be a string or a FormatFunc")
+}
+
+func init() {
+	Format(FormatCombined, `:remote-addr - :remote-user [:date[clf]] ":method :url HTTP/:http-version" :status :res[content-length] ":referrer" ":user-agent"`)
+	Format(FormatCommon, `:remote-addr - :remote-user [:date[clf]] ":method :url HTTP/:http-version" :status :res[content-length]`)
+	Format(FormatShort, `:remote-addr :remote-user :method :url HTTP/:http-version :status :res[content-length] - :response-time ms`)
+	Format(FormatTiny, `:method :url :status :res[content-length] - :response-time ms`)
+
+	// status codes are colored for development
+	Format(FormatDev, FormatFunc(func(req *expressgo.Request, res *expressgo.Response) string {
+		status := 0
+		if res.HeadersSent() {
+			status = res.StatusCode()
+		}
+
+		color := "0"
+		switch {
+		case status >= 500:
+			color = "31" // red
+		case status >= 400:
+			color = "33" // yellow
+		case status >= 300:
+			color = "36" // cyan
+		case status >= 200:
+			color = "32" // green
+		}
+
+		return Compile(":method :url \x1b["+color+"m:status\x1b[0m :response-time ms - :res[content-length]")(req, res)
+	}))
+}
+
+// Create the access logger middleware with a format name ("combined", "common", "dev", "short", "tiny"), a format string, or a FormatFunc.
+//
+// Lines are logged when responses are complete, or when requests come in if Immediate is set.
+func Use(format any, loggerConfig ...LoggerConfig) expressgo.Callback {
+	f, err := compileAny(format)
+	if err != nil {
+		panic(err)
+	}
+
+	// the default config
+	config := LoggerConfig{
+		Stream: os.Stdout,
+	}
+
+	// merge configs
+	if len(loggerConfig) > 0 {
+		userConfig := loggerConfig[0]
+
+		if userConfig.Immediate {
+			config.Immediate = userConfig.Immediate
+		}
+		if userConfig.Skip != nil {
+			config.Skip = userConfig.Skip
+		}
+		if userConfig.Stream != nil {
+			config.Stream = userConfig.Stream
+		}
+		if userConfig.Handler != nil {
+			config.Handler = userConfig.Handler
+		}
+	}
+
+	// lines from concurrent requests should not be interleaved
+	var mu sync.Mutex
+	var slogger *slog.Logger
+	if config.Handler != nil {
+		slogger = slog.New(config.Handler)
+	}
+
+	write := func(req *expressgo.Request, res *expressgo.Response) {
+		if config.Skip != nil && config.Skip(req, res) {
+			return
+		}
+
+		line := f(req, res)
+		if line == "" {
+			return
+		}
+
+		if slogger != nil {
+			attrs := []slog.Attr{
+				slog.String("method", req.Native.Method),
+				slog.String("url", req.OriginalUrl),
+			}
+			if res.HeadersSent() {
+				attrs = append(attrs, slog.Int("status", res.StatusCode()))
+			}
+			if t := getTiming(req); !t.headers.IsZero() {
+				attrs = append(attrs, slog.Duration("response-time", t.headers.Sub(t.start)))
+			}
+
+			slogger.LogAttrs(context.Background(), slog.LevelInfo, line, attrs...)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(config.Stream, line+"\n")
+	}
+
+	// create logger middleware
+	logger := func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		// proceed to the next callback
+		next.Next = true
+		next.Route = true
+
+		// timings are shared by loggers, the first logger records the start
+		t, ok := req.Native.Context().Value(timingKey{}).(*timing)
+		if !ok {
+			t = &timing{start: time.Now()}
+			req.Native = req.Native.WithContext(context.WithValue(req.Native.Context(), timingKey{}, t))
+		}
+
+		if config.Immediate {
+			write(req, res)
+			return
+		}
+
+		res.OnHeaders(func() error {
+			if t.headers.IsZero() {
+				t.headers = time.Now()
+			}
+			return nil
+		})
+
+		res.OnFinish(func() {
+			if t.finish.IsZero() {
+				t.finish = time.Now()
+			}
+			write(req, res)
+		})
+	}
+
+	return logger
+}
@@ -0,0 +1,109 @@
+package logger_test
+
+import (
+	"bytes"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/expressgotest"
+	"github.com/Eandalf/expressgo/logger"
+)
+
+// Serve a request with the logger and return the logged lines.
+func serveLogged(t *testing.T, format any, config logger.LoggerConfig, request func(agent *expressgotest.Agent)) string {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	config.Stream = buf
+
+	app := expressgo.CreateServer()
+	app.UseGlobal(logger.Use(format, config))
+	app.Get("/users/:id", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Status(201).Send("created")
+	})
+
+	request(expressgotest.New(&app))
+	return buf.String()
+}
+
+func TestFormats(t *testing.T) {
+	get := func(agent *expressgotest.Agent) {
+		agent.Get("/users/1?q=a").
+			Set("Referer", "http://example.com/").
+			Set("User-Agent", "test-agent").
+			Set("Authorization", "Basic dG9iaTpmZXJyZXQ=").
+			Expect(t)
+	}
+
+	tests := []struct {
+		format   any
+		expected *regexp.Regexp
+	}{
+		{logger.FormatCombined, regexp.MustCompile(`^192\.0\.2\.1 - tobi \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} \+0000\] "GET /users/1\?q=a HTTP/1\.1" 201 7 "http://example\.com/" "test-agent"\n$`)},
+		{logger.FormatCommon, regexp.MustCompile(`^192\.0\.2\.1 - tobi \[[^\]]+\] "GET /users/1\?q=a HTTP/1\.1" 201 7\n$`)},
+		{logger.FormatShort, regexp.MustCompile(`^192\.0\.2\.1 tobi GET /users/1\?q=a HTTP/1\.1 201 7 - \d+\.\d{3} ms\n$`)},
+		{logger.FormatTiny, regexp.MustCompile(`^GET /users/1\?q=a 201 7 - \d+\.\d{3} ms\n$`)},
+		{logger.FormatDev, regexp.MustCompile(`^GET /users/1\?q=a \x1b\[32m201\x1b\[0m \d+\.\d{3} ms - 7\n$`)},
+		// token arguments, missing values, and unknown tokens
+		{":req[user-agent] :res[content-length] :res[x-missing] :response-time[0] :total-time[1] :date[iso] :unknown", regexp.MustCompile(`^test-agent 7 - \d+ \d+\.\d \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z :unknown\n$`)},
+		{logger.FormatFunc(func(req *expressgo.Request, res *expressgo.Response) string {
+			return req.Params["id"] + " " + res.Get("Content-Length")
+		}), regexp.MustCompile(`^1 7\n$`)},
+	}
+
+	for _, test := range tests {
+		if line := serveLogged(t, test.format, logger.LoggerConfig{}, get); !test.expected.MatchString(line) {
+			t.Errorf("%v: expected a line matching %q, got %q", test.format, test.expected, line)
+		}
+	}
+}
+
+func TestCustomTokensAndFormats(t *testing.T) {
+	logger.Token("user-id", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		return strings.ToUpper(arg) + req.Params["id"]
+	})
+	logger.Format("custom", ":method :user-id[u]")
+
+	get := func(agent *expressgotest.Agent) {
+		agent.Get("/users/2").Expect(t)
+	}
+
+	if line := serveLogged(t, "custom", logger.LoggerConfig{}, get); line != "GET U2\n" {
+		t.Errorf("expected the custom format, got %q", line)
+	}
+
+	// response tokens are empty for immediate lines
+	if line := serveLogged(t, ":method :status :res[content-length]", logger.LoggerConfig{Immediate: true}, get); line != "GET - -\n" {
+		t.Errorf("expected an immediate line, got %q", line)
+	}
+
+	// skipped requests and empty lines are not logged
+	skip := func(req *expressgo.Request, res *expressgo.Response) bool {
+		return res.StatusCode() == 201
+	}
+	if line := serveLogged(t, logger.FormatTiny, logger.LoggerConfig{Skip: skip}, get); line != "" {
+		t.Errorf("expected the line skipped, got %q", line)
+	}
+	empty := func(req *expressgo.Request, res *expressgo.Response) string {
+		return ""
+	}
+	if line := serveLogged(t, empty, logger.LoggerConfig{}, get); line != "" {
+		t.Errorf("expected no line, got %q", line)
+	}
+
+	// lines are logged with attributes by slog handlers
+	buf := &bytes.Buffer{}
+	handler := slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey || a.Key == "response-time" {
+			return slog.Attr{}
+		}
+		return a
+	}})
+	serveLogged(t, logger.FormatTiny, logger.LoggerConfig{Handler: handler}, get)
+	if line := buf.String(); !regexp.MustCompile(`^level=INFO msg="GET /users/2 201 7 - \d+\.\d{3} ms" method=GET url=/users/2 status=201\n$`).MatchString(line) {
+		t.Errorf("expected a slog line, got %q", line)
+	}
+}
@@ -0,0 +1,150 @@
+package logger
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Eandalf/expressgo"
+)
+
+// A token returns its value for the request and the response, arg is the text in brackets, e.g., "content-length" of :res[content-length].
+//
+// An empty value is logged as "-".
+type TokenFunc func(req *expressgo.Request, res *expressgo.Response, arg string) string
+
+// name -> token
+var tokens = map[string]TokenFunc{}
+var tokensMu sync.RWMutex
+
+// Define a token, which could be used in formats as :name or :name[arg]. Predefined tokens could be overridden.
+func Token(name string, token TokenFunc) {
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+
+	tokens[name] = token
+}
+
+func getToken(name string) (TokenFunc, bool) {
+	tokensMu.RLock()
+	defer tokensMu.RUnlock()
+
+	token, ok := tokens[name]
+	return token, ok
+}
+
+// Timings of a request, kept in the request context for tokens.
+type timing struct {
+	start   time.Time
+	headers time.Time
+	finish  time.Time
+}
+
+type timingKey struct{}
+
+// Format milliseconds between the two times with the digits, which defaults to 3.
+func formatMilliseconds(from time.Time, to time.Time, arg string) string {
+	if from.IsZero() || to.IsZero() {
+		return ""
+	}
+
+	digits := 3
+	if d, err := strconv.Atoi(arg); err == nil && d >= 0 {
+		digits = d
+	}
+
+	ms := float64(to.Sub(from)) / float64(time.Millisecond)
+	return strconv.FormatFloat(ms, 'f', digits, 64)
+}
+
+func getTiming(req *expressgo.Request) *timing {
+	t, ok := req.Native.Context().Value(timingKey{}).(*timing)
+	if !ok {
+		return &timing{}
+	}
+
+	return t
+}
+
+func init() {
+	Token("url", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		if req.OriginalUrl != "" {
+			return req.OriginalUrl
+		}
+		return req.Native.URL.RequestURI()
+	})
+
+	Token("method", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		return req.Native.Method
+	})
+
+	// milliseconds from the request coming in to headers being sent
+	Token("response-time", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		t := getTiming(req)
+		return formatMilliseconds(t.start, t.headers, arg)
+	})
+
+	// milliseconds from the request coming in to the response being complete
+	Token("total-time", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		t := getTiming(req)
+		return formatMilliseconds(t.start, t.finish, arg)
+	})
+
+	// "clf", "iso", or "web", defaults to "web"
+	Token("date", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		now := time.Now().UTC()
+
+		switch arg {
+		case "clf":
+			return now.Format("02/Jan/2006:15:04:05 -0700")
+		case "iso":
+			return now.Format("2006-01-02T15:04:05.000Z07:00")
+		default:
+			return now.Format(http.TimeFormat)
+		}
+	})
+
+	Token("status", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		if !res.HeadersSent() {
+			return ""
+		}
+		return strconv.Itoa(res.StatusCode())
+	})
+
+	Token("referrer", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		if referrer := req.Get("Referer"); referrer != "" {
+			return referrer
+		}
+		return req.Get("Referrer")
+	})
+
+	Token("remote-addr", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		return req.Ip()
+	})
+
+	Token("remote-user", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		user, _, _ := req.Native.BasicAuth()
+		return user
+	})
+
+	Token("http-version", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		return strconv.Itoa(req.Native.ProtoMajor) + "." + strconv.Itoa(req.Native.ProtoMinor)
+	})
+
+	Token("user-agent", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		return req.Get("User-Agent")
+	})
+
+	Token("req", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		return strings.Join(req.Native.Header.Values(arg), ", ")
+	})
+
+	Token("res", func(req *expressgo.Request, res *expressgo.Response, arg string) string {
+		if !res.HeadersSent() {
+			return ""
+		}
+		return res.Get(arg)
+	})
+}
@@ -3,6 +3,30 @@ Write-Host "task: prepare"
 Write-Host "expressgo: tidy mod"
 go mod tidy -v
 
+Write-Host "goto: expressgo/mime"
+Push-Location ".\mime"
+
+Write-Host "expressgo/mime: format"
+go fmt
+
+Write-Host "expressgo/mime: install"
+go install -v
+
+Write-Host "goto: expressgo"
+Pop-Location
+
+Write-Host "goto: expressgo/expressgotest"
+Push-Location ".\expressgotest"
+
+Write-Host "expressgo/expressgotest: format"
+go fmt
+
+Write-Host "expressgo/expressgotest: install"
+go install -v
+
+Write-Host "goto: expressgo"
+Pop-Location
+
 Write-Host "goto: expressgo/examples/helloworld"
 Push-Location ".\examples\helloworld"
 
@@ -47,6 +71,66 @@ go install -v
 Write-Host "goto: expressgo"
 Pop-Location
 
+Write-Host "goto: expressgo/cookieparser"
+Push-Location ".\cookieparser"
+
+Write-Host "expressgo/cookieparser: format"
+go fmt
+
+Write-Host "expressgo/cookieparser: install"
+go install -v
+
+Write-Host "goto: expressgo"
+Pop-Location
+
+Write-Host "goto: expressgo/static"
+Push-Location ".\static"
+
+Write-Host "expressgo/static: format"
+go fmt
+
+Write-Host "expressgo/static: install"
+go install -v
+
+Write-Host "goto: expressgo"
+Pop-Location
+
+Write-Host "goto: expressgo/session"
+Push-Location ".\session"
+
+Write-Host "expressgo/session: format"
+go fmt
+
+Write-Host "expressgo/session: install"
+go install -v
+
+Write-Host "goto: expressgo"
+Pop-Location
+
+Write-Host "goto: expressgo/logger"
+Push-Location ".\logger"
+
+Write-Host "expressgo/logger: format"
+go fmt
+
+Write-Host "expressgo/logger: install"
+go install -v
+
+Write-Host "goto: expressgo"
+Pop-Location
+
+Write-Host "goto: expressgo/timeout"
+Push-Location ".\timeout"
+
+Write-Host "expressgo/timeout: format"
+go fmt
+
+Write-Host "expressgo/timeout: install"
+go install -v
+
+Write-Host "goto: expressgo"
+Pop-Location
+
 Write-Host "goto: expressgo/examples/helloworld"
 Push-Location ".\examples\helloworld"
 
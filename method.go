@@ -1,12 +1,22 @@
 package expressgo
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 )
 
 const (
-	configKeyAppEnv        = "APP_ENV"
-	configKeyCaseSensitive = "case sensitive routing"
+	configKeyAppEnv            = "APP_ENV"
+	configKeyAutoHead          = "auto head"
+	configKeyAutoOptions       = "auto options"
+	configKeyCaseSensitive     = "case sensitive routing"
+	configKeyJsonEscape        = "json escape"
+	configKeyJsonReplacer      = "json replacer"
+	configKeyJsonSpaces        = "json spaces"
+	configKeyJsonpCallbackName = "jsonp callback name"
+	configKeySubdomainOffset   = "subdomain offset"
+	configKeyTrustProxy        = "trust proxy"
 )
 
 var allMethods = [...]string{
@@ -31,11 +41,34 @@ var allMethods = [...]string{
 //
 // e.g., app.Set("case sensitive routing", true)
 func (app *App) Set(key string, value interface{}) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
 	switch key {
 	case configKeyCaseSensitive:
 		if isCaseSensitive, ok := value.(bool); ok {
 			app.config.caseSensitive = isCaseSensitive
 		}
+	case configKeyAutoHead:
+		if autoHead, ok := value.(bool); ok {
+			app.config.autoHead = autoHead
+		}
+	case configKeyAutoOptions:
+		if autoOptions, ok := value.(bool); ok {
+			app.config.autoOptions = autoOptions
+		}
+	case configKeyTrustProxy:
+		trust, err := compileTrust(value)
+		// a malformed value is refused, and the previous value is kept
+		if err != nil {
+			log.Println("expressgo refuses the setting:", err)
+			return
+		}
+		app.config.trustProxy = trust
+	case configKeySubdomainOffset:
+		if offset, ok := value.(int); ok && offset >= 0 {
+			app.config.subdomainOffset = offset
+		}
 	}
 
 	app.data[key] = value
@@ -43,6 +76,9 @@ func (app *App) Set(key string, value interface{}) {
 
 // Get data from the app global data table.
 func (app *App) GetData(key string) interface{} {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
 	if data, ok := app.data[key]; ok {
 		return data
 	}
@@ -50,12 +86,12 @@ func (app *App) GetData(key string) interface{} {
 }
 
 // Wrap normal callbacks with error checking logics.
-func (app *App) wrapCallbacks(callbacks []Callback) []Callback {
+func wrapCallbacks(callbacks []Callback) []Callback {
 	wrappedCallbacks := []Callback{}
 	for _, c := range callbacks {
 		var wc Callback = func(req *Request, res *Response, next *Next) {
 			// if an error needs to be handled, skip this callback
-			if req.err != nil {
+			if req.routing.err != nil {
 				return
 			}
 
@@ -67,15 +103,37 @@ func (app *App) wrapCallbacks(callbacks []Callback) []Callback {
 	return wrappedCallbacks
 }
 
+// Register a list of callbacks with the route formed by the method and the path.
+//
+// Return the registered routes, which are keys of app.callbacks.
+func (app *App) registerCallbacks(method string, path string, callbacks []Callback, middleware bool) ([]string, error) {
+	return app.handler.register(method, path, callbacks, middleware)
+}
+
+// Append a list of middlewares to the registered routes.
+func (app *App) appendCallbacks(routes []string, callbacks []Callback) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	for _, route := range routes {
+		app.callbacks[route] = append(app.callbacks[route], callbacks)
+		app.lists[route] = append(app.lists[route], listInfo{middleware: true})
+	}
+}
+
 // Mount the callbacks to all existing routes and future routes.
 //
 // This is an internal function that does not take wrapping callbacks into consideration.
 //
 // Callbacks passed into this function would not be wrapped with error-handling logics.
 func (app *App) useGlobal(callbacks []Callback) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
 	// add global middlewares to all existing routes
 	for route := range app.callbacks {
 		app.callbacks[route] = append(app.callbacks[route], callbacks)
+		app.lists[route] = append(app.lists[route], listInfo{middleware: true})
 	}
 
 	// push global middlewares to globalCallbacks for Handler.register to check and push to callbacks
@@ -84,18 +142,18 @@ func (app *App) useGlobal(callbacks []Callback) {
 
 // To mount middlewares to all existing routes and future routes made by app.[Method].
 func (app *App) UseGlobal(callbacks ...Callback) {
-	wc := app.wrapCallbacks(callbacks)
+	wc := wrapCallbacks(callbacks)
 	app.useGlobal(wc)
 }
 
-// Mount callbacks to the path with all http methods
+// Mount callbacks to the path with all http methods, as middlewares or as routes answering requests.
 //
 // This is an internal function that does not take wrapping callbacks into the consideration.
 //
 // Callbacks passed into this function would not be wrapped with error-handling logics.
-func (app *App) use(path string, callbacks []Callback) error {
+func (app *App) use(path string, callbacks []Callback, middleware bool) error {
 	for _, method := range allMethods {
-		err := app.handler.register(method, path, &UserHandler{app: app, callbacks: callbacks})
+		_, err := app.registerCallbacks(method, path, callbacks, middleware)
 		if err != nil {
 			return err
 		}
@@ -103,82 +161,187 @@ func (app *App) use(path string, callbacks []Callback) error {
 	return nil
 }
 
-// To mount callbacks as middlewares to the path with all http methods.
+// Register handlers of Use in the given order, where callbacks are mounted as middlewares by use, and routers are mounted to the target with the path as the prefix.
+func useHandlers(target registrar, path string, handlers []any, use func(callbacks []Callback) error) error {
+	callbacks := []Callback{}
+	mounted := false
+	for _, h := range handlers {
+		switch h := h.(type) {
+		case Callback:
+			callbacks = append(callbacks, h)
+		case func(*Request, *Response, *Next):
+			callbacks = append(callbacks, h)
+		case *Router:
+			// callbacks before the router are run before its routes
+			if len(callbacks) > 0 {
+				if err := use(callbacks); err != nil {
+					return err
+				}
+				callbacks = []Callback{}
+			}
+			if err := h.mount(target, path); err != nil {
+				return err
+			}
+			mounted = true
+		default:
+			return fmt.Errorf("handlers of Use should be callbacks or routers, instead %T is found", h)
+		}
+	}
+
+	if len(callbacks) > 0 || !mounted {
+		return use(callbacks)
+	}
+	return nil
+}
+
+// To mount callbacks as middlewares to the path with all http methods, or routers with the path as the prefix, see app.UseRouter.
 //
-// The order of invocation matters.
-func (app *App) Use(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.use(path, wc)
+// Handlers are expressgo.Callback or *expressgo.Router. The order of invocation matters. Requests passing through middlewares of a path without routes of the method are served as not found or method not allowed.
+func (app *App) Use(path string, handlers ...any) error {
+	return useHandlers(app, path, handlers, func(callbacks []Callback) error {
+		return app.use(path, wrapCallbacks(callbacks), true)
+	})
 }
 
 // To catch all http verbs on a path.
 //
-// Although the implementation is basically the same as app.Use, app.Use is for middlewares, app.All is for http verbs.
-//
-// It is more semantically correct to use app.All for all http verbs.
+// Unlike app.Use, the callbacks are routes answering requests, so the path is not served as not found, and its OPTIONS requests are not answered automatically.
 func (app *App) All(path string, callbacks ...Callback) error {
-	return app.Use(path, callbacks...)
+	wc := wrapCallbacks(callbacks)
+	return app.use(path, wc, false)
 }
 
 func (app *App) Get(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodGet, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodGet, path, wc, false)
+	return err
 }
 
 func (app *App) Head(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodHead, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodHead, path, wc, false)
+	return err
 }
 
 func (app *App) Post(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodPost, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodPost, path, wc, false)
+	return err
 }
 
 func (app *App) Put(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodPut, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodPut, path, wc, false)
+	return err
 }
 
 func (app *App) Patch(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodPatch, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodPatch, path, wc, false)
+	return err
 }
 
 func (app *App) Delete(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodDelete, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodDelete, path, wc, false)
+	return err
 }
 
 func (app *App) Connect(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodConnect, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodConnect, path, wc, false)
+	return err
 }
 
 func (app *App) Options(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodOptions, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodOptions, path, wc, false)
+	return err
 }
 
 func (app *App) Trace(path string, callbacks ...Callback) error {
-	wc := app.wrapCallbacks(callbacks)
-	return app.handler.register(http.MethodTrace, path, &UserHandler{app: app, callbacks: wc})
+	wc := wrapCallbacks(callbacks)
+	_, err := app.registerCallbacks(http.MethodTrace, path, wc, false)
+	return err
+}
+
+// Set callbacks for the param, which are run once per request before callbacks of a route with the param in its path.
+//
+// The value of the param is passed to the callbacks, e.g., to load the object it refers to and attach it to the request. Each callback should set next.Next or next.Route to go on with the next callback of the param, or the route after the last one, or next.Err to pass an error to error handlers.
+//
+// Callbacks are not run if the param is not found in the path, e.g., a missing optional param.
+func (app *App) Param(name string, callbacks ...ParamCallback) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	app.paramCallbacks[name] = append(app.paramCallbacks[name], wrapParamCallbacks(name, callbacks)...)
+}
+
+// Wrap callbacks of the param into callbacks, which are run in a list before callbacks of a route.
+func wrapParamCallbacks(name string, paramCallbacks []ParamCallback) []Callback {
+	callbacks := []Callback{}
+	for _, pc := range paramCallbacks {
+		var c Callback = func(req *Request, res *Response, next *Next) {
+			value, ok := req.Params[name]
+			// if an error needs to be handled or the param is missing, skip this callback
+			if req.routing.err != nil || !ok {
+				next.Next = true
+				next.Route = true
+				return
+			}
+
+			pc(req, res, next, value)
+
+			// next.Route goes on with the next callback of the params as next.Next does, and both go on with callbacks of the route after the last one
+			if next.Next || next.Route {
+				next.Next = true
+				next.Route = true
+			}
+		}
+		callbacks = append(callbacks, c)
+	}
+
+	return callbacks
+}
+
+// Get the callbacks set by app.Param for the params of a route, in the order of params in the path.
+//
+// A param found in multiple lists of callbacks of the route is counted once, so its callbacks are run once per request.
+//
+// The caller should hold app.mu.
+func (app *App) getParamCallbacks(params [][]string) []Callback {
+	callbacks := []Callback{}
+	seen := map[string]bool{}
+
+	// separators are placed between params, e.g., ["one", "0H", "two"]
+	for _, zone := range params {
+		for i := 0; i < len(zone); i += 2 {
+			if !seen[zone[i]] {
+				seen[zone[i]] = true
+				callbacks = append(callbacks, app.paramCallbacks[zone[i]]...)
+			}
+		}
+	}
+
+	return callbacks
 }
 
 // Wrap error callbacks into callbacks.
-func (app *App) wrapErrorCallbacks(errorCallbacks []ErrorCallback) []Callback {
+func wrapErrorCallbacks(errorCallbacks []ErrorCallback) []Callback {
 	callbacks := []Callback{}
 	for _, ec := range errorCallbacks {
 		var c Callback = func(req *Request, res *Response, next *Next) {
-			// if no error needs to be handled
-			if req.err == nil {
+			// if no error needs to be handled, skip this callback and go on, so error handlers registered before a route do not stop it
+			if req.routing.err == nil {
+				next.Next = true
+				next.Route = true
 				return
 			}
 
-			ec(req.err, req, res, next)
+			ec(req.routing.err, req, res, next)
 
 			// the error is consumed
-			req.err = nil
+			req.routing.err = nil
 		}
 		callbacks = append(callbacks, c)
 	}
@@ -188,12 +351,49 @@ func (app *App) wrapErrorCallbacks(errorCallbacks []ErrorCallback) []Callback {
 
 // To mount error handlers on a path with all http methods.
 func (app *App) UseError(path string, errorCallbacks ...ErrorCallback) {
-	callbacks := app.wrapErrorCallbacks(errorCallbacks)
-	app.use(path, callbacks)
+	callbacks := wrapErrorCallbacks(errorCallbacks)
+	app.use(path, callbacks, true)
 }
 
 // To mount error handlers to all routes.
 func (app *App) UseGlobalError(errorCallbacks ...ErrorCallback) {
-	callbacks := app.wrapErrorCallbacks(errorCallbacks)
+	callbacks := wrapErrorCallbacks(errorCallbacks)
 	app.useGlobal(callbacks)
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	// kept for handling errors from app.NotFound and app.MethodNotAllowed
+	*app.globalErrorCallbacks = append(*app.globalErrorCallbacks, callbacks)
+}
+
+// Set callbacks to requests matching no routes, replacing the default 404 response.
+//
+// They are run after global callbacks, so global middlewares and error handlers apply. Multiple calls append lists of callbacks.
+func (app *App) NotFound(callbacks ...Callback) {
+	wc := wrapCallbacks(callbacks)
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	*app.notFoundCallbacks = append(*app.notFoundCallbacks, wc)
+}
+
+// Set callbacks to requests matching routes of other methods only, replacing the default 405 response.
+//
+// The Allow header is set before they are run. They are run after global callbacks, as app.NotFound.
+func (app *App) MethodNotAllowed(callbacks ...Callback) {
+	wc := wrapCallbacks(callbacks)
+
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	*app.methodNotAllowedCallbacks = append(*app.methodNotAllowedCallbacks, wc)
+}
+
+// To mount a router on the path prefix, the same as app.Use with the router.
+//
+// Routes registered to the router, either before or after mounting, are served under the prefix.
+func (app *App) UseRouter(prefix string, router *Router) error {
+	return router.mount(app, prefix)
 }
@@ -0,0 +1,199 @@
+package mime
+
+import (
+	_ "embed"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type entry struct {
+	Source     string   `json:"source"`
+	Charset    string   `json:"charset"`
+	Extensions []string `json:"extensions"`
+}
+
+// mimeType -> entry
+var entries map[string]entry
+
+// extension -> mimeType
+var extensionTypes map[string]string
+
+//go:embed mime-db.json
+var mimeDb []byte
+
+// Map extensions to mime types, with the same preference as mime-types of Node.js.
+func init() {
+	entries = map[string]entry{}
+	extensionTypes = map[string]string{}
+	// the embedded database is broken, which should not happen with a released module
+	if err := json.Unmarshal(mimeDb, &entries); err != nil {
+		panic(err)
+	}
+
+	// sources with higher index are preferred
+	preference := []string{"nginx", "apache", "", "iana"}
+
+	sorted := []string{}
+	for t := range entries {
+		sorted = append(sorted, t)
+	}
+	slices.Sort(sorted)
+
+	for _, t := range sorted {
+		for _, ext := range entries[t].Extensions {
+			if existing, ok := extensionTypes[ext]; ok && existing != "application/octet-stream" {
+				from := slices.Index(preference, entries[existing].Source)
+				to := slices.Index(preference, entries[t].Source)
+				if from > to || (from == to && strings.HasPrefix(existing, "application/")) {
+					continue
+				}
+			}
+
+			extensionTypes[ext] = t
+		}
+	}
+}
+
+// Get the mime type of an extension, a file name, or a path, e.g., "json", ".html", or "dir/file.txt".
+//
+// Return "" if the extension is unknown.
+func Lookup(path string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext("x."+path), "."))
+	return extensionTypes[ext]
+}
+
+// Get the extensions of a mime type, the first one is the default. Params of the type are ignored.
+//
+// Return nil if the type is unknown.
+func Extensions(t string) []string {
+	return slices.Clone(entries[baseType(t)].Extensions)
+}
+
+// Get the default extension of a mime type, "" if the type is unknown.
+func Extension(t string) string {
+	exts := Extensions(t)
+	if len(exts) == 0 {
+		return ""
+	}
+
+	return exts[0]
+}
+
+// Get the default charset of a mime type, UTF-8 for text/* types without a known charset.
+//
+// Return "" if the type has no default charset.
+func Charset(t string) string {
+	t = baseType(t)
+
+	if charset := entries[t].Charset; charset != "" {
+		return charset
+	}
+	if strings.HasPrefix(t, "text/") {
+		return "UTF-8"
+	}
+
+	return ""
+}
+
+// Get the full Content-Type header of a mime type or an extension, with the default charset if the charset is not given.
+//
+// Return "" if the extension is unknown.
+func ContentType(t string) string {
+	if !strings.Contains(t, "/") {
+		t = Lookup(t)
+		if t == "" {
+			return ""
+		}
+	}
+
+	if !strings.Contains(strings.ToLower(t), "charset") {
+		if charset := Charset(t); charset != "" {
+			t += "; charset=" + strings.ToLower(charset)
+		}
+	}
+
+	return t
+}
+
+// Normalize a type to a mime type, which could contain wildcards.
+//
+// Shorthands: "urlencoded" -> application/x-www-form-urlencoded, "multipart" -> multipart/*, "+json" -> */*+json, and extensions, e.g., "json" -> application/json.
+//
+// Return "" if the extension is unknown.
+func Normalize(t string) string {
+	switch {
+	case t == "urlencoded":
+		return "application/x-www-form-urlencoded"
+	case t == "multipart":
+		return "multipart/*"
+	case strings.HasPrefix(t, "+"):
+		// "+json" -> "*/*+json" expando
+		return "*/*" + t
+	case !strings.Contains(t, "/"):
+		return Lookup(t)
+	}
+
+	return t
+}
+
+// Check if the actual mime type matches the expected one, where the expected one could contain wildcards, e.g., "text/*", "*/*", or "*/*+json".
+func Match(actual string, expected string) bool {
+	actualParts := strings.Split(actual, "/")
+	expectedParts := strings.Split(expected, "/")
+
+	if len(actualParts) != 2 || len(expectedParts) != 2 {
+		return false
+	}
+
+	if expectedParts[0] != "*" && expectedParts[0] != actualParts[0] {
+		return false
+	}
+
+	if strings.HasPrefix(expectedParts[1], "*+") {
+		return strings.HasSuffix(actualParts[1], expectedParts[1][1:])
+	}
+
+	if expectedParts[1] != "*" && expectedParts[1] != actualParts[1] {
+		return false
+	}
+
+	return true
+}
+
+// Check if the value of a Content-Type header matches any of the types, which are normalized by Normalize.
+//
+// Return the matched type as it is given, or the mime type of the value if the matched type contains wildcards or starts with "+".
+//
+// Without types, the mime type of the value is returned. Return "" if nothing matches or the value is malformed.
+func Is(value string, types ...string) string {
+	actual := baseType(value)
+	if !strings.Contains(actual, "/") {
+		return ""
+	}
+
+	if len(types) == 0 {
+		return actual
+	}
+
+	for _, t := range types {
+		expected := strings.ToLower(Normalize(t))
+		if expected == "" || !Match(actual, expected) {
+			continue
+		}
+
+		if strings.HasPrefix(t, "+") || strings.Contains(t, "*") {
+			return actual
+		}
+		return t
+	}
+
+	return ""
+}
+
+// Remove params from a mime type, e.g., "text/html; charset=utf-8" -> "text/html".
+func baseType(t string) string {
+	t, _, _ = strings.Cut(t, ";")
+	return strings.ToLower(strings.TrimSpace(t))
+}
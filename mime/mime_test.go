@@ -0,0 +1,105 @@
+package mime_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Eandalf/expressgo/mime"
+)
+
+func TestLookup(t *testing.T) {
+	tests := map[string]string{
+		"json":           "application/json",
+		".html":          "text/html",
+		"dir/file.TXT":   "text/plain",
+		"archive.tar.gz": "application/gzip",
+		"image.png":      "image/png",
+		"unknown":        "",
+		"":               "",
+	}
+
+	for path, expected := range tests {
+		if got := mime.Lookup(path); got != expected {
+			t.Errorf("Lookup(%q): expected %q, got %q", path, expected, got)
+		}
+	}
+}
+
+func TestExtensionsAndContentType(t *testing.T) {
+	if exts := mime.Extensions("text/html; charset=utf-8"); !slices.Contains(exts, "html") {
+		t.Errorf("expected html in extensions, got %v", exts)
+	}
+	if ext := mime.Extension("application/json"); ext != "json" {
+		t.Errorf("expected json, got %q", ext)
+	}
+	if ext := mime.Extension("application/x-unknown"); ext != "" {
+		t.Errorf("expected no extension, got %q", ext)
+	}
+
+	tests := map[string]string{
+		"json":                       "application/json; charset=utf-8",
+		"html":                       "text/html; charset=utf-8",
+		"text/x-custom":              "text/x-custom; charset=utf-8",
+		"png":                        "image/png",
+		"text/plain; charset=latin1": "text/plain; charset=latin1",
+		"application/x-unknown":      "application/x-unknown",
+		"unknown":                    "",
+	}
+
+	for value, expected := range tests {
+		if got := mime.ContentType(value); got != expected {
+			t.Errorf("ContentType(%q): expected %q, got %q", value, expected, got)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		actual   string
+		expected string
+		matched  bool
+	}{
+		{"text/html", "text/html", true},
+		{"text/html", "text/*", true},
+		{"text/html", "*/*", true},
+		{"application/vnd.api+json", "*/*+json", true},
+		{"application/json", "*/*+json", false},
+		{"text/html", "application/*", false},
+		{"text/html", "text/plain", false},
+		{"text", "text/*", false},
+		{"text/html", "*", false},
+	}
+
+	for _, test := range tests {
+		if got := mime.Match(test.actual, test.expected); got != test.matched {
+			t.Errorf("Match(%q, %q): expected %v, got %v", test.actual, test.expected, test.matched, got)
+		}
+	}
+}
+
+func TestIs(t *testing.T) {
+	tests := []struct {
+		value    string
+		types    []string
+		expected string
+	}{
+		{"application/json; charset=utf-8", nil, "application/json"},
+		{"application/json", []string{"json"}, "json"},
+		{"application/json", []string{"html", "application/json"}, "application/json"},
+		{"text/html; charset=utf-8", []string{"text/*"}, "text/html"},
+		{"application/vnd.api+json", []string{"+json"}, "application/vnd.api+json"},
+		{"application/x-www-form-urlencoded", []string{"urlencoded"}, "urlencoded"},
+		{"multipart/form-data; boundary=x", []string{"multipart"}, "multipart"},
+		{"multipart/form-data; boundary=x", []string{"multipart/*"}, "multipart/form-data"},
+		{"TEXT/HTML", []string{"html"}, "html"},
+		{"application/json", []string{"html", "unknown"}, ""},
+		{"invalid", []string{"json"}, ""},
+		{"", nil, ""},
+	}
+
+	for _, test := range tests {
+		if got := mime.Is(test.value, test.types...); got != test.expected {
+			t.Errorf("Is(%q, %q): expected %q, got %q", test.value, test.types, test.expected, got)
+		}
+	}
+}
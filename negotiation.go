@@ -0,0 +1,398 @@
+package expressgo
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Eandalf/expressgo/mime"
+)
+
+var ErrNotAcceptable = errors.New("406: not acceptable")
+
+// An entry in Accept headers, e.g., text/html;level=1;q=0.5.
+type acceptEntry struct {
+	value  string
+	params map[string]string
+	q      float64
+	// position in the header
+	index int
+}
+
+// Parse the entries of an Accept header, in the same way as negotiator of Node.js.
+func parseAccept(header string) []acceptEntry {
+	entries := []acceptEntry{}
+	for i, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := strings.TrimSpace(params[0])
+		if value == "" {
+			continue
+		}
+
+		entry := acceptEntry{value: value, params: map[string]string{}, q: 1, index: i}
+		for _, param := range params[1:] {
+			k, v, _ := strings.Cut(param, "=")
+			k = strings.ToLower(strings.TrimSpace(k))
+			v = strings.Trim(strings.TrimSpace(v), `"`)
+
+			if k == "q" {
+				if q, err := strconv.ParseFloat(v, 64); err == nil {
+					entry.q = q
+				}
+				continue
+			}
+			entry.params[k] = v
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// A provided value with the priority from the entry matching it best.
+type priority struct {
+	value string
+	q     float64
+	// specificity of the matched entry
+	s int
+	// position of the matched entry in the header
+	o int
+	// position of the provided value
+	i int
+}
+
+// Sort the provided values by preferences in the header, values not acceptable are dropped.
+//
+// match returns the specificity of an entry matching the value, or -1 if the entry does not match.
+func negotiate(entries []acceptEntry, provided []string, match func(entry acceptEntry, value string) int) []string {
+	priorities := []priority{}
+	for i, value := range provided {
+		best := priority{value: value, q: 0, s: -1, o: -1, i: i}
+		for _, entry := range entries {
+			s := match(entry, value)
+			if s < 0 {
+				continue
+			}
+
+			// prefer more specific entries, then higher qualities, then earlier entries
+			if best.s < 0 || s > best.s || (s == best.s && (entry.q > best.q || (entry.q == best.q && entry.index < best.o))) {
+				best.q = entry.q
+				best.s = s
+				best.o = entry.index
+			}
+		}
+
+		if best.s >= 0 && best.q > 0 {
+			priorities = append(priorities, best)
+		}
+	}
+
+	sort.SliceStable(priorities, func(a, b int) bool {
+		pa, pb := priorities[a], priorities[b]
+		if pa.q != pb.q {
+			return pa.q > pb.q
+		}
+		if pa.s != pb.s {
+			return pa.s > pb.s
+		}
+		if pa.o != pb.o {
+			return pa.o < pb.o
+		}
+		return pa.i < pb.i
+	})
+
+	values := []string{}
+	for _, p := range priorities {
+		values = append(values, p.value)
+	}
+
+	return values
+}
+
+// Get the values of the entries sorted by qualities, values not acceptable are dropped.
+func sortAccepted(entries []acceptEntry) []string {
+	accepted := slices.Clone(entries)
+	sort.SliceStable(accepted, func(a, b int) bool {
+		return accepted[a].q > accepted[b].q
+	})
+
+	values := []string{}
+	for _, entry := range accepted {
+		if entry.q > 0 {
+			values = append(values, entry.value)
+		}
+	}
+
+	return values
+}
+
+// Resolve a type or an extension, e.g., "json" or ".html", to a mime type, "" if it is unknown.
+func lookupType(t string) string {
+	if strings.Contains(t, "/") {
+		return t
+	}
+
+	return mime.Lookup(t)
+}
+
+// Match a media range against a mime type with params, e.g., text/*;level=1.
+func matchMediaType(entry acceptEntry, value string) int {
+	parsed := parseAccept(value)
+	if len(parsed) == 0 {
+		return -1
+	}
+
+	t, subtype, _ := strings.Cut(strings.ToLower(parsed[0].value), "/")
+	entryType, entrySubtype, _ := strings.Cut(strings.ToLower(entry.value), "/")
+
+	s := 0
+	if entryType == t {
+		s |= 4
+	} else if entryType != "*" {
+		return -1
+	}
+	if entrySubtype == subtype {
+		s |= 2
+	} else if entrySubtype != "*" {
+		return -1
+	}
+
+	if len(entry.params) > 0 {
+		for k, v := range entry.params {
+			if v != "*" && !strings.EqualFold(v, parsed[0].params[k]) {
+				return -1
+			}
+		}
+		s |= 1
+	}
+
+	return s
+}
+
+// Match a token, e.g., a charset or an encoding, case-insensitively, "*" matches all.
+func matchToken(entry acceptEntry, value string) int {
+	if strings.EqualFold(entry.value, value) {
+		return 1
+	}
+	if entry.value == "*" {
+		return 0
+	}
+
+	return -1
+}
+
+// Match a language tag, where prefixes match in both directions, e.g., en and en-US.
+func matchLanguage(entry acceptEntry, value string) int {
+	prefix, _, _ := strings.Cut(value, "-")
+	entryPrefix, _, _ := strings.Cut(entry.value, "-")
+
+	switch {
+	case strings.EqualFold(entry.value, value):
+		return 4
+	case strings.EqualFold(entryPrefix, value):
+		return 2
+	case strings.EqualFold(entry.value, prefix):
+		return 1
+	case entry.value == "*":
+		return 0
+	}
+
+	return -1
+}
+
+// Get the best type of the types acceptable by the Accept header, "" if none is acceptable.
+//
+// Types could be mime types (e.g., "application/json") or extensions (e.g., "json", "html"). The type is returned as it is given.
+//
+// Without types, the first type in the Accept header by quality is returned.
+func (req *Request) Accepts(types ...string) string {
+	return firstOf(req.acceptedTypes(types))
+}
+
+// Get the types acceptable by the Accept header, sorted by preferences.
+func (req *Request) acceptedTypes(types []string) []string {
+	header := req.Get("Accept")
+	// any type is acceptable without the header
+	if strings.TrimSpace(header) == "" {
+		header = "*/*"
+	}
+	entries := parseAccept(header)
+
+	if len(types) == 0 {
+		return sortAccepted(entries)
+	}
+
+	// resolve extensions to mime types, and map them back to the given types
+	mimeTypes := []string{}
+	given := map[string]string{}
+	for _, t := range types {
+		m := lookupType(t)
+		if m == "" {
+			continue
+		}
+		if _, ok := given[m]; !ok {
+			given[m] = t
+			mimeTypes = append(mimeTypes, m)
+		}
+	}
+
+	accepted := []string{}
+	for _, m := range negotiate(entries, mimeTypes, matchMediaType) {
+		accepted = append(accepted, given[m])
+	}
+
+	return accepted
+}
+
+// Get the best charset of the charsets acceptable by the Accept-Charset header, "" if none is acceptable.
+//
+// Without charsets, the first charset in the header by quality is returned.
+func (req *Request) AcceptsCharsets(charsets ...string) string {
+	header := req.Get("Accept-Charset")
+	if strings.TrimSpace(header) == "" {
+		header = "*"
+	}
+
+	return firstOf(negotiateOrSort(parseAccept(header), charsets, matchToken))
+}
+
+// Get the best encoding of the encodings acceptable by the Accept-Encoding header, "" if none is acceptable.
+//
+// "identity" is acceptable unless it is refused explicitly. Without encodings, the first encoding in the header by quality is returned.
+func (req *Request) AcceptsEncodings(encodings ...string) string {
+	header := req.Get("Accept-Encoding")
+	entries := parseAccept(header)
+	if len(req.Native.Header.Values("Accept-Encoding")) == 0 {
+		entries = parseAccept("*")
+	}
+
+	// identity is acceptable with the lowest quality in the header if it is not listed
+	hasIdentity := false
+	minQ := 1.0
+	for _, entry := range entries {
+		if strings.EqualFold(entry.value, "identity") || entry.value == "*" {
+			hasIdentity = true
+		}
+		minQ = min(minQ, entry.q)
+	}
+	if !hasIdentity {
+		entries = append(entries, acceptEntry{value: "identity", params: map[string]string{}, q: minQ, index: len(entries)})
+	}
+
+	return firstOf(negotiateOrSort(entries, encodings, matchToken))
+}
+
+// Get the best language of the languages acceptable by the Accept-Language header, "" if none is acceptable.
+//
+// Without languages, the first language in the header by quality is returned.
+func (req *Request) AcceptsLanguages(languages ...string) string {
+	header := req.Get("Accept-Language")
+	if strings.TrimSpace(header) == "" {
+		header = "*"
+	}
+
+	return firstOf(negotiateOrSort(parseAccept(header), languages, matchLanguage))
+}
+
+// Negotiate the provided values, or sort the entries if no value is provided.
+func negotiateOrSort(entries []acceptEntry, provided []string, match func(entry acceptEntry, value string) int) []string {
+	if len(provided) == 0 {
+		return sortAccepted(entries)
+	}
+
+	return negotiate(entries, provided, match)
+}
+
+func firstOf(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+// Add the field to the Vary header if it is not listed.
+func (res *Response) Vary(field string) {
+	values := []string{}
+	for _, v := range strings.Split(res.Get("Vary"), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	for _, v := range values {
+		// all fields vary already
+		if v == "*" || strings.EqualFold(v, field) {
+			return
+		}
+	}
+
+	if field == "*" {
+		res.Set("Vary", "*")
+		return
+	}
+
+	res.Set("Vary", strings.Join(append(values, field), ", "))
+}
+
+// A type with the callback responding with it, see res.FormatOrdered.
+type Formatter struct {
+	// a mime type or an extension, see req.Accepts, or "default" for the callback run if no type is acceptable
+	Type     string
+	Callback Callback
+}
+
+// Run the callback of the type best acceptable by the Accept header, with Content-Type set to the type. Vary: Accept is set.
+//
+// Keys are mime types or extensions, see req.Accepts. Since maps are not ordered, types with the same preference are chosen in the alphabetical order, e.g., for */*, see res.FormatOrdered to choose them in a given order.
+//
+// If no type is acceptable, the callback of "default" is run, or ErrNotAcceptable is passed to error-handling callbacks.
+func (res *Response) Format(callbacks map[string]Callback) {
+	formatters := []Formatter{}
+	for _, t := range slices.Sorted(maps.Keys(callbacks)) {
+		formatters = append(formatters, Formatter{Type: t, Callback: callbacks[t]})
+	}
+	res.FormatOrdered(formatters...)
+}
+
+// The same as res.Format, but types with the same preference are chosen in the given order, so the first type is chosen for */*.
+func (res *Response) FormatOrdered(formatters ...Formatter) {
+	req := res.req
+	res.Vary("Accept")
+
+	// the callbacks go on with the next of the callback calling this
+	next := req.routing.next
+	if next == nil {
+		next = &Next{}
+	}
+
+	types := []string{}
+	for _, f := range formatters {
+		if f.Type != "default" {
+			types = append(types, f.Type)
+		}
+	}
+
+	if t := req.Accepts(types...); t != "" {
+		res.Type(t)
+
+		i := slices.IndexFunc(formatters, func(f Formatter) bool {
+			return f.Type == t
+		})
+		formatters[i].Callback(req, res, next)
+		return
+	}
+
+	if i := slices.IndexFunc(formatters, func(f Formatter) bool {
+		return f.Type == "default"
+	}); i >= 0 {
+		formatters[i].Callback(req, res, next)
+		return
+	}
+
+	next.Err = ErrNotAcceptable
+}
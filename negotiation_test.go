@@ -0,0 +1,125 @@
+package expressgo
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAccepts(t *testing.T) {
+	app := CreateServer()
+	app.Get("/type", func(req *Request, res *Response, next *Next) {
+		res.Send(req.Accepts(strings.Split(req.Query["types"], ",")...))
+	})
+	app.Get("/charset", func(req *Request, res *Response, next *Next) {
+		res.Send(req.AcceptsCharsets(strings.Split(req.Query["values"], ",")...))
+	})
+	app.Get("/encoding", func(req *Request, res *Response, next *Next) {
+		res.Send(req.AcceptsEncodings(strings.Split(req.Query["values"], ",")...))
+	})
+	app.Get("/language", func(req *Request, res *Response, next *Next) {
+		res.Send(req.AcceptsLanguages(strings.Split(req.Query["values"], ",")...))
+	})
+
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		value    string
+		expected string
+	}{
+		{"missing header", "/type?types=json,html", "Accept", "", "json"},
+		{"q-values", "/type?types=html,json", "Accept", "text/html;q=0.5, application/json", "json"},
+		{"extensions and mime types", "/type?types=text/html,json", "Accept", "text/html", "text/html"},
+		{"subtype wildcard", "/type?types=json,png", "Accept", "image/*", "png"},
+		{"full wildcard", "/type?types=png,json", "Accept", "*/*", "png"},
+		// more specific entries take precedence over wildcards
+		{"specificity", "/type?types=json,html", "Accept", "*/*;q=0.8, text/html", "html"},
+		{"refused", "/type?types=json,html", "Accept", "*/*, application/json;q=0", "html"},
+		// ties are broken by the order in the header, then the given order
+		{"header order", "/type?types=json,html", "Accept", "text/html, application/json", "html"},
+		{"given order", "/type?types=html,json", "Accept", "text/*, application/*", "html"},
+		{"not acceptable", "/type?types=json,html", "Accept", "image/png", ""},
+		{"charset", "/charset?values=utf-8,iso-8859-1", "Accept-Charset", "iso-8859-1, utf-8;q=0.5", "iso-8859-1"},
+		{"encoding", "/encoding?values=gzip,deflate", "Accept-Encoding", "deflate, gzip;q=0.5", "deflate"},
+		{"identity", "/encoding?values=identity,gzip", "Accept-Encoding", "br", "identity"},
+		{"identity refused", "/encoding?values=identity", "Accept-Encoding", "*;q=0", ""},
+		{"language prefix", "/language?values=fr,en", "Accept-Language", "en-US, fr;q=0.5", "en"},
+	}
+
+	for _, test := range tests {
+		resp := serveWithHeader(&app, test.target, test.header, test.value)
+		if body, _ := io.ReadAll(resp.Body); string(body) != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, body)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	send := func(body string) Callback {
+		return func(req *Request, res *Response, next *Next) {
+			res.Send(body)
+		}
+	}
+
+	app := CreateServer()
+	app.Get("/", func(req *Request, res *Response, next *Next) {
+		res.Format(map[string]Callback{
+			"json": send("json"),
+			"html": send("html"),
+			"text": send("text"),
+		})
+	})
+	app.Get("/default", func(req *Request, res *Response, next *Next) {
+		res.Format(map[string]Callback{
+			"json":    send("json"),
+			"default": send("default"),
+		})
+	})
+	app.Get("/ordered", func(req *Request, res *Response, next *Next) {
+		res.FormatOrdered(
+			Formatter{Type: "json", Callback: send("json")},
+			Formatter{Type: "text", Callback: send("text")},
+			Formatter{Type: "html", Callback: send("html")},
+		)
+	})
+	app.UseGlobalError(func(err error, req *Request, res *Response, next *Next) {
+		if err == ErrNotAcceptable {
+			res.Status(http.StatusNotAcceptable).Send(err.Error())
+		}
+	})
+
+	tests := []struct {
+		name   string
+		target string
+		accept string
+		status int
+		body   string
+		ctype  string
+	}{
+		// ties of the map are broken by the alphabetical order
+		{"any", "/", "*/*", http.StatusOK, "html", "text/html; charset=utf-8"},
+		{"missing header", "/", "", http.StatusOK, "html", "text/html; charset=utf-8"},
+		{"q-values", "/", "text/html;q=0.5, text/plain", http.StatusOK, "text", "text/plain; charset=utf-8"},
+		{"tie", "/", "text/*", http.StatusOK, "html", "text/html; charset=utf-8"},
+		{"not acceptable", "/", "image/png", http.StatusNotAcceptable, ErrNotAcceptable.Error(), ""},
+		{"default", "/default", "image/png", http.StatusOK, "default", ""},
+		// ties of the list are broken by the given order
+		{"ordered any", "/ordered", "*/*", http.StatusOK, "json", "application/json; charset=utf-8"},
+		{"ordered tie", "/ordered", "text/*", http.StatusOK, "text", "text/plain; charset=utf-8"},
+		{"ordered not acceptable", "/ordered", "image/png", http.StatusNotAcceptable, ErrNotAcceptable.Error(), ""},
+	}
+
+	for _, test := range tests {
+		resp := serveWithHeader(&app, test.target, "Accept", test.accept)
+		b, _ := io.ReadAll(resp.Body)
+		body := string(b)
+		if resp.StatusCode != test.status || body != test.body || (test.ctype != "" && resp.Header.Get("Content-Type") != test.ctype) {
+			t.Errorf("%s: expected %d %q %q, got %d %q %q", test.name, test.status, test.ctype, test.body, resp.StatusCode, resp.Header.Get("Content-Type"), body)
+		}
+		if resp.Header.Get("Vary") != "Accept" {
+			t.Errorf("%s: expected Vary: Accept, got %q", test.name, resp.Header.Get("Vary"))
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package expressgo
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/Eandalf/expressgo/mime"
 )
 
 type Request struct {
@@ -11,13 +14,89 @@ type Request struct {
 	Params map[string]string
 	Query  map[string]string
 	Body   interface{}
-	err    error
+	// files uploaded in multipart bodies by field names, set by multipart parsers
+	Files map[string][]*File
+	// cookies set by the cookie parser, nil if the parser is not used
+	Cookies map[string]string
+	// valid signed cookies set by the cookie parser, nil if the parser is not used
+	SignedCookies map[string]string
+	// the secret for signing cookies, set by the cookie parser
+	Secret string
+	// the session set by session middlewares, nil if no session is used or it is destroyed
+	Session Session
+	// values scoped to the request, shared with res.Locals and the context of req.Native, see LocalsFrom
+	Locals Locals
+	// the path on which the current router is mounted, "" outside routers
+	BaseUrl string
+	// the request URL as sent by the client, before any path rewriting
+	OriginalUrl string
+	// the path part of OriginalUrl
+	originalPath string
+	// per-request routing state
+	routing *routing
+	app     *App
+}
+
+// Implemented by sessions set by session middlewares, e.g., expressgo/session.
+type Session interface {
+	// Get the id of the session.
+	Id() string
+	// Get a value from the session.
+	Get(key string) any
+	// Set a value to the session.
+	Set(key string, value any)
+	// Delete a value from the session.
+	Delete(key string)
+	// Replace the session with a new one with a new id.
+	Regenerate() error
+	// Destroy the session and unset req.Session.
+	Destroy() error
+	// Save the session to the store.
+	Save() error
+	// Reload the session from the store.
+	Reload() error
+	// Reset the expiration of the session.
+	Touch()
 }
 
 type BodyJsonBase map[string]json.RawMessage
 
 type BodyFormUrlEncoded map[string]string
 
+// A file uploaded in a multipart body.
+type File struct {
+	// name of the field in the form
+	FieldName string
+	// name of the file on the client
+	OriginalName string
+	// Content-Transfer-Encoding of the part, defaults to "7bit"
+	Encoding string
+	MimeType string
+	Size     int64
+	// the directory where the file is saved, set by disk storages
+	Destination string
+	// name of the file in Destination, set by disk storages
+	FileName string
+	// the full path of the saved file, set by disk storages
+	Path string
+	// the content of the file, set by memory storages
+	Buffer []byte
+}
+
+// Get the context of the request, which is cancelled when the client disconnects or the connection is closed by the server, or when the server started by app.Listen is closed, see Server.Shutdown.
+func (req *Request) Context() context.Context {
+	return req.Native.Context()
+}
+
+// Replace the context of the request for the rest of the chain, e.g., with a deadline or values.
+//
+// The context should be derived from req.Context(), otherwise values kept in it, e.g., request locals, are lost.
+//
+// If the context is cancelled, the chain stops before the next callback. If it is cancelled with a cause, e.g., by context.WithTimeoutCause, the cause is passed to error handlers instead.
+func (req *Request) WithContext(ctx context.Context) {
+	req.Native = req.Native.WithContext(ctx)
+}
+
 // Get a request header specified by the field. The field is case-insensitive.
 func (req *Request) Get(field string) string {
 	values := req.Native.Header.Values(field)
@@ -28,3 +107,17 @@ func (req *Request) Get(field string) string {
 func (req *Request) Header(field string) string {
 	return req.Get(field)
 }
+
+// Check if Content-Type of the request matches any of the types, e.g., "json", "text/*", "application/*+json", or "+json".
+//
+// Return the matched type as it is given, or the mime type of the request if the matched type contains wildcards or starts with "+".
+//
+// Without types, the mime type of the request is returned. Return "" if nothing matches or the request has no body.
+func (req *Request) Is(types ...string) string {
+	// a request without Content-Length or Transfer-Encoding has no body
+	if req.Native.ContentLength == 0 && len(req.Native.TransferEncoding) == 0 {
+		return ""
+	}
+
+	return mime.Is(req.Get("Content-Type"), types...)
+}
@@ -1,26 +1,180 @@
 package expressgo
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/Eandalf/expressgo/mime"
 )
 
+var ErrResponseEnded = errors.New("response has ended")
+
 type Response struct {
-	native     http.ResponseWriter
+	// values scoped to the request, the same as req.Locals
+	Locals Locals
+	native http.ResponseWriter
+	app    *App
+	// the request being responded
+	req        *Request
 	end        bool
 	statusCode int
-	body       string
+	// whether the status code and headers have been written to the client
+	headersSent bool
+	// functions called right before headers are sent
+	onHeaders []func() error
+	// the error returned by a function registered by res.OnHeaders, which fails the response
+	headersErr error
+	// functions called after all callbacks are run
+	onFinish []func()
+}
+
+// Write the status code and headers to the client. Headers could not be modified afterwards.
+func (res *Response) writeHeader() {
+	if res.headersSent {
+		return
+	}
+
+	// hooks could still modify the status code and headers
+	hooks := res.onHeaders
+	res.onHeaders = nil
+	for _, hook := range hooks {
+		if err := hook(); err != nil {
+			// nothing is sent, the error is passed to error handlers after the current callback
+			res.headersErr = err
+			res.end = true
+			return
+		}
+	}
+
+	res.native.WriteHeader(res.StatusCode())
+	res.headersSent = true
 }
 
-// Stop further writes to the response.
+// Stop further writes to the response. The status code and headers are sent if they are not sent yet.
 func (res *Response) End() {
+	if res.end {
+		return
+	}
+
+	res.writeHeader()
 	res.end = true
 }
 
+// Register a function to be called right before the status code and headers are sent, where headers could still be set.
+//
+// If the function returns an error, nothing is sent and the error is passed to error handlers, which respond instead.
+func (res *Response) OnHeaders(hook func() error) {
+	res.onHeaders = append(res.onHeaders, hook)
+}
+
+// Register a function to be called after all callbacks are run and the response is complete.
+//
+// It is called even if a callback panics, so resources of the request, e.g., temporary files, could be released there.
+func (res *Response) OnFinish(hook func()) {
+	res.onFinish = append(res.onFinish, hook)
+}
+
+// Complete the response after all callbacks are run.
+func (res *Response) finish() {
+	// commit the status code and headers if no callback has sent them
+	res.writeHeader()
+
+	// no callback is left to respond to the failure of hooks
+	if res.headersErr != nil && !res.headersSent {
+		res.native.WriteHeader(http.StatusInternalServerError)
+		res.headersSent = true
+	}
+}
+
+// Run functions registered by res.OnFinish.
+func (res *Response) runOnFinish() {
+	for _, hook := range res.onFinish {
+		hook()
+	}
+}
+
+// Check if the status code and headers have been sent to the client.
+func (res *Response) HeadersSent() bool {
+	return res.headersSent
+}
+
+// Write bytes to the response body. The status code and headers are sent with the first write.
+//
+// Response implements io.Writer, so it could be used to stream the body.
+func (res *Response) Write(p []byte) (int, error) {
+	if res.end {
+		return 0, ErrResponseEnded
+	}
+
+	res.writeHeader()
+	if res.headersErr != nil {
+		return 0, res.headersErr
+	}
+
+	// responses to HEAD requests have no body, Content-Length set before is kept
+	if res.req.Native.Method == http.MethodHead {
+		return len(p), nil
+	}
+
+	return res.native.Write(p)
+}
+
+// Send buffered data to the client. The status code and headers are sent if they are not sent yet.
+//
+// Response implements http.Flusher. It is a no-op if the underlying ResponseWriter could not flush.
+func (res *Response) Flush() {
+	if res.end {
+		return
+	}
+
+	res.writeHeader()
+	if res.headersErr != nil {
+		return
+	}
+	http.NewResponseController(res.native).Flush()
+}
+
+// An http.ResponseWriter writing through the Response.
+type responseWriter struct {
+	res *Response
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.res.native.Header()
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if !w.res.headersSent {
+		w.res.statusCode = statusCode
+	}
+	w.res.writeHeader()
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	return w.res.Write(p)
+}
+
+// For http.ResponseController to reach the underlying ResponseWriter.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.res.native
+}
+
+// Get an http.ResponseWriter writing through the response, for handing the response to net/http functions.
+//
+// Headers sent and writes made through it are seen by res.HeadersSent, res.End, and other methods.
+func (res *Response) Writer() http.ResponseWriter {
+	return &responseWriter{res: res}
+}
+
 // Add a value to a response header, field: value. The field is case-insensitive.
 func (res *Response) Append(field string, value string) {
-	// if end is already designated, this method should be a no-op
-	if res.end {
+	// if end is already designated or headers are sent, this method should be a no-op
+	if res.end || res.headersSent {
 		return
 	}
 
@@ -29,8 +183,8 @@ func (res *Response) Append(field string, value string) {
 
 // Set a response header, field: value. The field is case-insensitive.
 func (res *Response) Set(field string, value string) {
-	// if end is already designated, this method should be a no-op
-	if res.end {
+	// if end is already designated or headers are sent, this method should be a no-op
+	if res.end || res.headersSent {
 		return
 	}
 
@@ -58,6 +212,19 @@ func (res *Response) Get(field string) string {
 	return strings.Join(values, ",")
 }
 
+// Set Content-Type to the mime type, it is chainable.
+//
+// The type could be a mime type, e.g., "text/html", or an extension, e.g., "json" and ".html". The default charset of the type is appended. Unknown extensions are set as application/octet-stream.
+func (res *Response) Type(t string) *Response {
+	contentType := mime.ContentType(t)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	res.Set("Content-Type", contentType)
+	return res
+}
+
 // Send the response.
 func (res *Response) Send(body string) {
 	// if end is already designated, this method should be a no-op
@@ -65,8 +232,13 @@ func (res *Response) Send(body string) {
 		return
 	}
 
-	res.body = body
-	res.end = true
+	// the length is known only if nothing has been written
+	if !res.headersSent {
+		res.native.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	res.Write([]byte(body))
+	res.End()
 }
 
 // Send the response with a status code.
@@ -76,12 +248,154 @@ func (res *Response) SendStatus(statusCode int) {
 		return
 	}
 
-	res.statusCode = statusCode
-	res.end = true
+	res.Status(statusCode)
+	res.End()
 }
 
 // Set the HTTP status code of the response, it is chainable.
+//
+// It has no effect once headers are sent.
 func (res *Response) Status(statusCode int) *Response {
-	res.statusCode = statusCode
+	if !res.headersSent {
+		res.statusCode = statusCode
+	}
 	return res
 }
+
+// Get the HTTP status code of the response, which defaults to 200.
+func (res *Response) StatusCode() int {
+	if res.statusCode == 0 {
+		return http.StatusOK
+	}
+	return res.statusCode
+}
+
+// Apply the replacer to the decoded JSON value and its children, in the way JSON.stringify does.
+func replaceJson(replacer func(string, any) any, key string, value any) any {
+	value = replacer(key, value)
+
+	switch v := value.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = replaceJson(replacer, k, item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = replaceJson(replacer, strconv.Itoa(i), item)
+		}
+	}
+
+	return value
+}
+
+// Encode the body as JSON with app settings "json escape", "json replacer", and "json spaces".
+func (res *Response) stringify(body any) (string, error) {
+	escape, _ := res.app.GetData(configKeyJsonEscape).(bool)
+
+	indent := ""
+	if spaces, ok := res.app.GetData(configKeyJsonSpaces).(int); ok && spaces > 0 {
+		indent = strings.Repeat(" ", spaces)
+	} else if spaces, ok := res.app.GetData(configKeyJsonSpaces).(string); ok {
+		indent = spaces
+	}
+
+	// the replacer works on the decoded value, which consists of map[string]any, []any, json.Number, string, bool, and nil
+	if replacer, ok := res.app.GetData(configKeyJsonReplacer).(func(string, any) any); ok && replacer != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return "", err
+		}
+
+		var value any
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err != nil {
+			return "", err
+		}
+
+		body = replaceJson(replacer, "", value)
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(escape)
+	if indent != "" {
+		encoder.SetIndent("", indent)
+	}
+	if err := encoder.Encode(body); err != nil {
+		return "", err
+	}
+
+	// remove the newline added by the encoder
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// Send the body encoded as JSON. Content-Type is set to application/json if it is not set.
+//
+// Return the error if the body could not be encoded, nothing is sent in that case.
+func (res *Response) Json(body any) error {
+	// if end is already designated, this method should be a no-op
+	if res.end {
+		return nil
+	}
+
+	s, err := res.stringify(body)
+	if err != nil {
+		return err
+	}
+
+	if res.native.Header().Get("Content-Type") == "" {
+		res.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	res.Send(s)
+	return nil
+}
+
+var jsonpCallbackExclude = regexp.MustCompile(`[^\[\]\w$.]`)
+
+// Send the body encoded as JSON with JSONP support.
+//
+// The callback is read from the query string by the name set with app setting "jsonp callback name", which defaults to "callback".
+// Without the callback, it is the same as res.Json.
+//
+// Return the error if the body could not be encoded, nothing is sent in that case.
+func (res *Response) Jsonp(body any) error {
+	// if end is already designated, this method should be a no-op
+	if res.end {
+		return nil
+	}
+
+	s, err := res.stringify(body)
+	if err != nil {
+		return err
+	}
+
+	name, ok := res.app.GetData(configKeyJsonpCallbackName).(string)
+	if !ok || name == "" {
+		name = "callback"
+	}
+
+	// restrict the charset of the callback
+	callback := jsonpCallbackExclude.ReplaceAllString(res.req.Query[name], "")
+
+	if callback == "" {
+		if res.native.Header().Get("Content-Type") == "" {
+			res.Set("Content-Type", "application/json; charset=utf-8")
+		}
+
+		res.Send(s)
+		return nil
+	}
+
+	res.Set("X-Content-Type-Options", "nosniff")
+	res.Set("Content-Type", "text/javascript; charset=utf-8")
+
+	// replace chars not allowed in JavaScript that are in JSON
+	s = strings.ReplaceAll(s, "\u2028", "\\u2028")
+	s = strings.ReplaceAll(s, "\u2029", "\\u2029")
+
+	// the /**/ is a specific security mitigation for "Rosetta Flash JSONP abuse"
+	res.Send("/**/ typeof " + callback + " === 'function' && " + callback + "(" + s + ");")
+	return nil
+}
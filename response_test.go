@@ -0,0 +1,130 @@
+package expressgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Send a request to the app and return the response with the body read.
+func serveResponse(app App, method string, target string) (*http.Response, string) {
+	w := httptest.NewRecorder()
+	app.handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	return w.Result(), string(body)
+}
+
+func TestResponseWrite(t *testing.T) {
+	app := CreateServer()
+
+	var errAfterEnd error
+	app.Get("/stream", func(req *Request, res *Response, next *Next) {
+		res.Status(http.StatusCreated)
+		res.Set("X-Stream", "true")
+		fmt.Fprint(res, "one,")
+		res.Flush()
+
+		// headers are sent with the first write
+		res.Status(http.StatusTeapot)
+		res.Set("X-Late", "true")
+
+		res.Write([]byte("two"))
+		res.End()
+		_, errAfterEnd = res.Write([]byte("three"))
+	})
+	app.Get("/send", func(req *Request, res *Response, next *Next) {
+		res.Send("hello")
+		res.Send("again")
+	})
+	app.Get("/end", func(req *Request, res *Response, next *Next) {
+		res.Status(http.StatusNoContent).End()
+	})
+
+	resp, body := serveResponse(app, http.MethodGet, "/stream")
+	if resp.StatusCode != http.StatusCreated || body != "one,two" {
+		t.Errorf("/stream: expected 201 %q, got %d %q", "one,two", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Stream") != "true" || resp.Header.Get("X-Late") != "" {
+		t.Errorf("/stream: expected headers set before the first write only, got %v", resp.Header)
+	}
+	if !errors.Is(errAfterEnd, ErrResponseEnded) {
+		t.Errorf("/stream: expected ErrResponseEnded after res.End, got %v", errAfterEnd)
+	}
+
+	resp, body = serveResponse(app, http.MethodGet, "/send")
+	if resp.StatusCode != http.StatusOK || body != "hello" || resp.Header.Get("Content-Length") != "5" {
+		t.Errorf("/send: expected 200 %q with Content-Length 5, got %d %q %q", "hello", resp.StatusCode, body, resp.Header.Get("Content-Length"))
+	}
+
+	resp, body = serveResponse(app, http.MethodGet, "/end")
+	if resp.StatusCode != http.StatusNoContent || body != "" {
+		t.Errorf("/end: expected 204 without a body, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestResponseJson(t *testing.T) {
+	body := map[string]any{"html": "<b>&</b>", "secret": "x", "list": []int{1}}
+
+	tests := []struct {
+		name     string
+		settings map[string]any
+		target   string
+		ctype    string
+		expected string
+	}{
+		{"default", nil, "/json", "application/json; charset=utf-8", `{"html":"<b>&</b>","list":[1],"secret":"x"}`},
+		{"escape", map[string]any{"json escape": true}, "/json", "application/json; charset=utf-8", `{"html":"\u003cb\u003e\u0026\u003c/b\u003e","list":[1],"secret":"x"}`},
+		{"spaces", map[string]any{"json spaces": 2}, "/json", "application/json; charset=utf-8", "{\n  \"html\": \"<b>&</b>\",\n  \"list\": [\n    1\n  ],\n  \"secret\": \"x\"\n}"},
+		{"spaces string", map[string]any{"json spaces": "\t"}, "/json", "application/json; charset=utf-8", "{\n\t\"html\": \"<b>&</b>\",\n\t\"list\": [\n\t\t1\n\t],\n\t\"secret\": \"x\"\n}"},
+		{"replacer", map[string]any{"json replacer": func(key string, value any) any {
+			if key == "secret" {
+				return nil
+			}
+			return value
+		}}, "/json", "application/json; charset=utf-8", `{"html":"<b>&</b>","list":[1],"secret":null}`},
+		{"jsonp without callback", nil, "/jsonp", "application/json; charset=utf-8", `{"html":"<b>&</b>","list":[1],"secret":"x"}`},
+		{"jsonp", nil, "/jsonp?callback=cb.fn", "text/javascript; charset=utf-8", `/**/ typeof cb.fn === 'function' && cb.fn({"html":"<b>&</b>","list":[1],"secret":"x"});`},
+		{"jsonp sanitized", nil, "/jsonp?callback=alert(1)", "text/javascript; charset=utf-8", `/**/ typeof alert1 === 'function' && alert1({"html":"<b>&</b>","list":[1],"secret":"x"});`},
+		{"jsonp callback name", map[string]any{"jsonp callback name": "cb"}, "/jsonp?cb=fn&callback=other", "text/javascript; charset=utf-8", `/**/ typeof fn === 'function' && fn({"html":"<b>&</b>","list":[1],"secret":"x"});`},
+	}
+
+	for _, test := range tests {
+		app := CreateServer()
+		for key, value := range test.settings {
+			app.Set(key, value)
+		}
+		app.Get("/json", func(req *Request, res *Response, next *Next) {
+			next.Err = res.Json(body)
+		})
+		app.Get("/jsonp", func(req *Request, res *Response, next *Next) {
+			next.Err = res.Jsonp(body)
+		})
+
+		resp, got := serveResponse(app, http.MethodGet, test.target)
+		if resp.StatusCode != http.StatusOK || got != test.expected || resp.Header.Get("Content-Type") != test.ctype {
+			t.Errorf("%s: expected 200 %q %q, got %d %q %q", test.name, test.ctype, test.expected, resp.StatusCode, resp.Header.Get("Content-Type"), got)
+		}
+	}
+
+	// line separators are escaped in JSONP
+	app := CreateServer()
+	app.Get("/jsonp", func(req *Request, res *Response, next *Next) {
+		next.Err = res.Jsonp("a\u2028b")
+	})
+	if _, got := serveResponse(app, http.MethodGet, "/jsonp?callback=cb"); got != `/**/ typeof cb === 'function' && cb("a\u2028b");` {
+		t.Errorf("expected escaped line separators, got %q", got)
+	}
+
+	// unsupported values are returned as errors with nothing sent
+	app.Get("/invalid", func(req *Request, res *Response, next *Next) {
+		if err := res.Json(make(chan int)); err != nil {
+			res.Status(http.StatusInternalServerError).Send("invalid")
+		}
+	})
+	if status, got := serve(app, http.MethodGet, "/invalid"); status != http.StatusInternalServerError || got != "invalid" {
+		t.Errorf("expected 500 %q, got %d %q", "invalid", status, got)
+	}
+}
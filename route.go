@@ -0,0 +1,95 @@
+package expressgo
+
+import (
+	"net/http"
+)
+
+// A route builder sharing the path among methods, created by app.Route or router.Route.
+//
+// Registrations stop at the first error, which is returned by route.Err.
+type Route struct {
+	target registrar
+	path   string
+	err    error
+}
+
+// Create a route builder for the path, e.g., app.Route("/book").Get(getBook).Put(putBook).Err().
+func (app *App) Route(path string) *Route {
+	return &Route{target: app, path: path}
+}
+
+// Create a route builder for the path relative to the router. See app.Route.
+func (r *Router) Route(path string) *Route {
+	return &Route{target: r, path: path}
+}
+
+// Register the callbacks with the methods as middlewares or routes, unless an error is found before.
+func (route *Route) register(methods []string, callbacks []Callback, middleware bool) *Route {
+	for _, method := range methods {
+		if route.err != nil {
+			return route
+		}
+
+		_, route.err = route.target.registerCallbacks(method, route.path, callbacks, middleware)
+	}
+
+	return route
+}
+
+// Get the first error found while registering callbacks of the route.
+func (route *Route) Err() error {
+	return route.err
+}
+
+// To mount callbacks as middlewares to the route with all http methods.
+//
+// The order of invocation matters. They are run before callbacks of methods registered after them.
+func (route *Route) Use(callbacks ...Callback) *Route {
+	return route.register(allMethods[:], wrapCallbacks(callbacks), true)
+}
+
+// To catch all http verbs on the route. See app.All.
+func (route *Route) All(callbacks ...Callback) *Route {
+	return route.register(allMethods[:], wrapCallbacks(callbacks), false)
+}
+
+// To mount error handlers on the route with all http methods.
+func (route *Route) UseError(errorCallbacks ...ErrorCallback) *Route {
+	return route.register(allMethods[:], wrapErrorCallbacks(errorCallbacks), true)
+}
+
+func (route *Route) Get(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodGet}, wrapCallbacks(callbacks), false)
+}
+
+func (route *Route) Head(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodHead}, wrapCallbacks(callbacks), false)
+}
+
+func (route *Route) Post(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodPost}, wrapCallbacks(callbacks), false)
+}
+
+func (route *Route) Put(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodPut}, wrapCallbacks(callbacks), false)
+}
+
+func (route *Route) Patch(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodPatch}, wrapCallbacks(callbacks), false)
+}
+
+func (route *Route) Delete(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodDelete}, wrapCallbacks(callbacks), false)
+}
+
+func (route *Route) Connect(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodConnect}, wrapCallbacks(callbacks), false)
+}
+
+func (route *Route) Options(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodOptions}, wrapCallbacks(callbacks), false)
+}
+
+func (route *Route) Trace(callbacks ...Callback) *Route {
+	return route.register([]string{http.MethodTrace}, wrapCallbacks(callbacks), false)
+}
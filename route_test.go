@@ -0,0 +1,61 @@
+package expressgo
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	app := CreateServer()
+
+	err := app.Route("/book/:id").
+		All(func(req *Request, res *Response, next *Next) {
+			req.Params["all"] = "all"
+			next.Route = true
+		}).
+		Get(func(req *Request, res *Response, next *Next) {
+			res.Send("get " + req.Params["id"] + " " + req.Params["all"])
+		}).
+		Post(func(req *Request, res *Response, next *Next) {
+			res.Send("post " + req.Params["id"] + " " + req.Params["all"])
+		}).
+		Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := CreateRouter()
+	err = router.Route("/:name").
+		Get(func(req *Request, res *Response, next *Next) {
+			res.Send("hello " + req.Params["name"])
+		}).
+		Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.UseRouter("/hello", router); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{http.MethodGet, "/book/1", "get 1 all"},
+		{http.MethodPost, "/book/2", "post 2 all"},
+		{http.MethodGet, "/hello/world", "hello world"},
+	}
+
+	for _, test := range tests {
+		if status, body := serve(app, test.method, test.target); status != http.StatusOK || body != test.body {
+			t.Errorf("%s %s: expected 200 %q, got %d %q", test.method, test.target, test.body, status, body)
+		}
+	}
+
+	// registrations stop at the first error
+	route := app.Route("/broken/:1id")
+	if route.Get(sendParams()).Post(sendParams()).Err() == nil {
+		t.Error("expected an error for the invalid param name")
+	}
+}
@@ -0,0 +1,424 @@
+package expressgo
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Implemented by App and Router to accept lists of callbacks from routers mounted on them.
+type registrar interface {
+	// register a list of callbacks with the routes formed by the method and the path, and return the registered routes
+	//
+	// middlewares, e.g., of app.Use, do not make the routes answer requests on their own
+	registerCallbacks(method string, path string, callbacks []Callback, middleware bool) ([]string, error)
+	// append a list of middlewares to the registered routes
+	appendCallbacks(routes []string, callbacks []Callback)
+}
+
+type routerConfig struct {
+	mergeParams bool
+}
+
+type RouterConfig struct {
+	// to keep params of the parent on req.Params
+	MergeParams bool
+}
+
+// A registration forwarded by a router to its mount points.
+type record struct {
+	method string
+	path   string
+	// if routes is not nil, the record appends the callbacks to the routes instead of registering a new one
+	routes     []string
+	callbacks  []Callback
+	middleware bool
+}
+
+type Router struct {
+	config *routerConfig
+	// lists of callbacks, format: [[c11, c12, c13], [c21, c22]], set by router.UseGlobal
+	globalCallbacks [][]Callback
+	// routes registered to the router, "METHOD path" -> true
+	routes map[string]bool
+	// registrations forwarded to mount points, replayed when the router is mounted again
+	records []record
+	mounts  []*mount
+}
+
+// A mount point of a router.
+type mount struct {
+	router *Router
+	target registrar
+	prefix string
+	// number of path segments in the prefix
+	segments int
+	// routes of the router -> routes of the target, a route with optional segments is registered as multiple routes of an app
+	routes map[string][]string
+}
+
+func CreateRouter(config ...RouterConfig) *Router {
+	router := &Router{
+		config:          &routerConfig{},
+		globalCallbacks: [][]Callback{},
+		routes:          map[string]bool{},
+		records:         []record{},
+		mounts:          []*mount{},
+	}
+	if len(config) > 0 {
+		c := config[0]
+		router.config.mergeParams = c.MergeParams
+	}
+
+	return router
+}
+
+// Join the prefix of a mount point and the path of a route.
+func joinPath(prefix string, path string) string {
+	joined := strings.TrimSuffix(prefix, "/") + path
+	if joined == "" {
+		return "/"
+	}
+	return joined
+}
+
+// Check the path is relative to the mount point and return the params found in it.
+func parseRouterPath(path string) ([]string, error) {
+	if path == "" || path[0] != '/' {
+		return []string{}, errors.New("path of a router should start with a slash (/), instead " + path + " is found")
+	}
+
+	h := new(Handler)
+	path, _, err := h.parseConstraints(path)
+	if err != nil {
+		return []string{}, err
+	}
+	paths, err := h.expandOptional(path)
+	if err != nil {
+		return []string{}, err
+	}
+
+	params := []string{}
+	for _, path := range paths {
+		_, zones, err := h.parseParams(path)
+		if err != nil {
+			return []string{}, err
+		}
+
+		// separators are placed between params, e.g., ["one", "0H", "two"]
+		for _, zone := range zones {
+			for i := 0; i < len(zone); i += 2 {
+				if !slices.Contains(params, zone[i]) {
+					params = append(params, zone[i])
+				}
+			}
+		}
+	}
+
+	return params, nil
+}
+
+// Return the part of the path matched by the prefix of the mount point.
+func (m *mount) matchPrefix(path string) string {
+	end := 0
+	for i := 0; i < m.segments; i++ {
+		if end+1 > len(path) {
+			return path
+		}
+
+		pos := strings.IndexByte(path[end+1:], '/')
+		if pos < 0 {
+			return path
+		}
+		end += pos + 1
+	}
+
+	return path[:end]
+}
+
+// Wrap callbacks of the router to set req.BaseUrl and req.Params seen inside the router, where params are the params of the routes of the router.
+func (m *mount) wrapCallbacks(callbacks []Callback, params []string) []Callback {
+	wrappedCallbacks := []Callback{}
+	for _, c := range callbacks {
+		var wc Callback = func(req *Request, res *Response, next *Next) {
+			baseUrl, parentParams := req.BaseUrl, req.Params
+
+			req.BaseUrl = baseUrl + m.matchPrefix(strings.TrimPrefix(req.originalPath, baseUrl))
+
+			// params found in prefixes belong to the parents
+			if !m.router.config.mergeParams {
+				req.Params = map[string]string{}
+				for _, p := range params {
+					if v, ok := parentParams[p]; ok {
+						req.Params[p] = v
+					}
+				}
+			}
+
+			c(req, res, next)
+
+			// restore the values seen by the parent
+			req.BaseUrl, req.Params = baseUrl, parentParams
+		}
+		wrappedCallbacks = append(wrappedCallbacks, wc)
+	}
+
+	return wrappedCallbacks
+}
+
+// Apply a registration of the router to the target of the mount point.
+func (m *mount) apply(r record) error {
+	if r.routes != nil {
+		routes := []string{}
+		seen := map[string]bool{}
+		for _, route := range r.routes {
+			// different routes of the router could be registered as the same route of the target
+			for _, targetRoute := range m.routes[route] {
+				if !seen[targetRoute] {
+					seen[targetRoute] = true
+					routes = append(routes, targetRoute)
+				}
+			}
+		}
+
+		// params of all the routes, which are parsed when the routes are registered
+		params := []string{}
+		for _, route := range r.routes {
+			_, path, _ := strings.Cut(route, " ")
+			routeParams, _ := parseRouterPath(path)
+			for _, p := range routeParams {
+				if !slices.Contains(params, p) {
+					params = append(params, p)
+				}
+			}
+		}
+
+		m.target.appendCallbacks(routes, m.wrapCallbacks(r.callbacks, params))
+		return nil
+	}
+
+	params, err := parseRouterPath(r.path)
+	if err != nil {
+		return err
+	}
+
+	targetRoutes, err := m.target.registerCallbacks(r.method, joinPath(m.prefix, r.path), m.wrapCallbacks(r.callbacks, params), r.middleware)
+	if err != nil {
+		return err
+	}
+	m.routes[r.method+" "+r.path] = targetRoutes
+
+	return nil
+}
+
+// Record the registration and pass it to all mount points.
+func (r *Router) forward(rec record) error {
+	r.records = append(r.records, rec)
+
+	for _, m := range r.mounts {
+		if err := m.apply(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Router) registerCallbacks(method string, path string, callbacks []Callback, middleware bool) ([]string, error) {
+	if _, err := parseRouterPath(path); err != nil {
+		return []string{}, err
+	}
+
+	route := method + " " + path
+
+	// register existing global middlewares first for first-seen routes
+	if !r.routes[route] {
+		r.routes[route] = true
+
+		for _, gc := range r.globalCallbacks {
+			if err := r.forward(record{method: method, path: path, callbacks: gc, middleware: true}); err != nil {
+				return []string{}, err
+			}
+		}
+	}
+
+	return []string{route}, r.forward(record{method: method, path: path, callbacks: callbacks, middleware: middleware})
+}
+
+func (r *Router) appendCallbacks(routes []string, callbacks []Callback) {
+	r.forward(record{routes: routes, callbacks: callbacks})
+}
+
+// Check if the router is the ancestor or is mounted under the ancestor.
+func (r *Router) isMountedUnder(ancestor *Router) bool {
+	if r == ancestor {
+		return true
+	}
+
+	for _, m := range r.mounts {
+		if parent, ok := m.target.(*Router); ok && parent.isMountedUnder(ancestor) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Mount the router on the target with the prefix, and replay all registrations made before.
+func (r *Router) mount(target registrar, prefix string) error {
+	if _, err := parseRouterPath(prefix); err != nil {
+		return err
+	}
+
+	// the number of segments in the prefix should be fixed to find req.BaseUrl
+	if strings.ContainsAny(prefix, "{}") {
+		return errors.New("prefix of a router cannot contain optional segments, " + prefix + " is found")
+	}
+
+	if parent, ok := target.(*Router); ok && parent.isMountedUnder(r) {
+		return errors.New("router cannot be mounted under itself")
+	}
+
+	segments := 0
+	for _, s := range strings.Split(prefix, "/") {
+		if s != "" {
+			segments += 1
+		}
+	}
+
+	m := &mount{
+		router:   r,
+		target:   target,
+		prefix:   prefix,
+		segments: segments,
+		routes:   map[string][]string{},
+	}
+	r.mounts = append(r.mounts, m)
+
+	for _, rec := range r.records {
+		if err := m.apply(rec); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Mount the callbacks to all existing routes and future routes of the router.
+//
+// Callbacks passed into this function would not be wrapped with error-handling logics.
+func (r *Router) useGlobal(callbacks []Callback) {
+	r.globalCallbacks = append(r.globalCallbacks, callbacks)
+
+	routes := []string{}
+	for route := range r.routes {
+		routes = append(routes, route)
+	}
+	r.appendCallbacks(routes, callbacks)
+}
+
+// To mount middlewares to all existing routes and future routes made by router.[Method].
+func (r *Router) UseGlobal(callbacks ...Callback) {
+	wc := wrapCallbacks(callbacks)
+	r.useGlobal(wc)
+}
+
+// Mount callbacks to the path with all http methods, as middlewares or as routes answering requests.
+//
+// Callbacks passed into this function would not be wrapped with error-handling logics.
+func (r *Router) use(path string, callbacks []Callback, middleware bool) error {
+	for _, method := range allMethods {
+		_, err := r.registerCallbacks(method, path, callbacks, middleware)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// To mount callbacks as middlewares to the path with all http methods, or routers with the path as the prefix relative to the router. See app.Use.
+//
+// The order of invocation matters.
+func (r *Router) Use(path string, handlers ...any) error {
+	return useHandlers(r, path, handlers, func(callbacks []Callback) error {
+		return r.use(path, wrapCallbacks(callbacks), true)
+	})
+}
+
+// To catch all http verbs on a path. See app.All.
+func (r *Router) All(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	return r.use(path, wc, false)
+}
+
+func (r *Router) Get(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodGet, path, wc, false)
+	return err
+}
+
+func (r *Router) Head(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodHead, path, wc, false)
+	return err
+}
+
+func (r *Router) Post(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodPost, path, wc, false)
+	return err
+}
+
+func (r *Router) Put(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodPut, path, wc, false)
+	return err
+}
+
+func (r *Router) Patch(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodPatch, path, wc, false)
+	return err
+}
+
+func (r *Router) Delete(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodDelete, path, wc, false)
+	return err
+}
+
+func (r *Router) Connect(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodConnect, path, wc, false)
+	return err
+}
+
+func (r *Router) Options(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodOptions, path, wc, false)
+	return err
+}
+
+func (r *Router) Trace(path string, callbacks ...Callback) error {
+	wc := wrapCallbacks(callbacks)
+	_, err := r.registerCallbacks(http.MethodTrace, path, wc, false)
+	return err
+}
+
+// To mount error handlers on a path with all http methods.
+func (r *Router) UseError(path string, errorCallbacks ...ErrorCallback) {
+	callbacks := wrapErrorCallbacks(errorCallbacks)
+	r.use(path, callbacks, true)
+}
+
+// To mount error handlers to all routes of the router.
+func (r *Router) UseGlobalError(errorCallbacks ...ErrorCallback) {
+	callbacks := wrapErrorCallbacks(errorCallbacks)
+	r.useGlobal(callbacks)
+}
+
+// To mount a router on the path prefix relative to the router, the same as router.Use with the router.
+func (r *Router) UseRouter(prefix string, router *Router) error {
+	return router.mount(r, prefix)
+}
@@ -0,0 +1,134 @@
+package expressgo
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Respond with req.BaseUrl, req.OriginalUrl and params in the order of names.
+func sendUrls(names ...string) Callback {
+	return func(req *Request, res *Response, next *Next) {
+		body := req.BaseUrl + " " + req.OriginalUrl
+		for _, name := range names {
+			body += " " + name + "=" + req.Params[name]
+		}
+		res.Send(body)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	app := CreateServer()
+
+	users := CreateRouter()
+	if err := users.Get("/:id", sendUrls("id", "version")); err != nil {
+		t.Fatal(err)
+	}
+
+	api := CreateRouter(RouterConfig{MergeParams: true})
+	if err := api.Get("/version", sendUrls("version")); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Use("/users", users); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Use("/api/:version", api); err != nil {
+		t.Fatal(err)
+	}
+
+	// routes registered after mounting are forwarded to the app
+	if err := users.Get("/:id/posts", sendUrls("id")); err != nil {
+		t.Fatal(err)
+	}
+	if err := api.Get("/status", sendUrls()); err != nil {
+		t.Fatal(err)
+	}
+
+	// routes registered before mounting are replayed to the app
+	admin := CreateRouter()
+	if err := admin.Get("/", sendUrls()); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.UseRouter("/admin", admin); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		target string
+		body   string
+	}{
+		// params of prefixes are kept with MergeParams only
+		{"/api/v1/version", "/api/v1 /api/v1/version version=v1"},
+		{"/api/v1/users/101", "/api/v1/users /api/v1/users/101 id=101 version="},
+		{"/api/v2/users/101/posts?page=2", "/api/v2/users /api/v2/users/101/posts?page=2 id=101"},
+		{"/api/v2/status", "/api/v2 /api/v2/status"},
+		{"/admin", "/admin /admin"},
+	}
+
+	for _, test := range tests {
+		if status, body := serve(app, http.MethodGet, test.target); status != http.StatusOK || body != test.body {
+			t.Errorf("%s: expected 200 %q, got %d %q", test.target, test.body, status, body)
+		}
+	}
+
+	// req.BaseUrl and req.Params are restored after callbacks of routers
+	if err := users.Get("/:id/next", func(req *Request, res *Response, next *Next) {
+		next.Route = true
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Get("/api/:version/users/:id/next", sendUrls("id", "version")); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := serve(app, http.MethodGet, "/api/v3/users/7/next"); status != http.StatusOK || body != " /api/v3/users/7/next id=7 version=v3" {
+		t.Errorf("expected the values of the app after the router, got %d %q", status, body)
+	}
+}
+
+func TestUseWithRouters(t *testing.T) {
+	app := CreateServer()
+
+	router := CreateRouter()
+	if err := router.Get("/", func(req *Request, res *Response, next *Next) {
+		res.Send(req.Locals["mark"].(string) + " " + req.BaseUrl)
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// callbacks and routers are mounted in the given order
+	if err := app.Use("/mixed", func(req *Request, res *Response, next *Next) {
+		req.Locals["mark"] = "before"
+		next.Route = true
+	}, router); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := serve(app, http.MethodGet, "/mixed"); status != http.StatusOK || body != "before /mixed" {
+		t.Errorf("expected the middleware run before the router, got %d %q", status, body)
+	}
+
+	if err := app.Use("/invalid", http.NotFoundHandler()); err == nil {
+		t.Error("expected an error for a handler neither a callback nor a router")
+	}
+}
+
+func TestRouterMountErrors(t *testing.T) {
+	app := CreateServer()
+
+	parent := CreateRouter()
+	child := CreateRouter()
+
+	if err := parent.UseRouter("/child", child); err != nil {
+		t.Fatal(err)
+	}
+	if err := parent.UseRouter("/self", parent); err == nil {
+		t.Error("expected an error for mounting a router under itself")
+	}
+	if err := child.UseRouter("/parent", parent); err == nil {
+		t.Error("expected an error for mounting a router under its child")
+	}
+	if err := app.UseRouter("/users{/:id}", child); err == nil {
+		t.Error("expected an error for optional segments in the prefix")
+	}
+	if err := app.UseRouter("users", child); err == nil {
+		t.Error("expected an error for a prefix without a leading slash")
+	}
+}
@@ -0,0 +1,367 @@
+package expressgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrSendFileForbidden = errors.New("403: forbidden")
+var ErrSendFileNotFound = errors.New("404: not found")
+
+// Values of SendFileConfig.Dotfiles.
+const (
+	SendFileDotfilesAllow  = "allow"
+	SendFileDotfilesDeny   = "deny"
+	SendFileDotfilesIgnore = "ignore"
+)
+
+// A hook to set headers before a file is sent, path is relative to the root if the root is set.
+type SendFileSetHeaders func(res *Response, path string, stat fs.FileInfo)
+
+type SendFileConfig struct {
+	AcceptRanges     any
+	acceptRangesBool bool
+	CacheControl     any
+	cacheControlBool bool
+	Dotfiles         string
+	Etag             any
+	etagBool         bool
+	Headers          map[string]string
+	Immutable        bool
+	LastModified     any
+	lastModifiedBool bool
+	MaxAge           time.Duration
+	// the directory (string) or the fs.FS which the path is relative to
+	Root       any
+	SetHeaders SendFileSetHeaders
+}
+
+// Strip Accept-Ranges set by http.ServeContent if ranges are not accepted.
+type noRangesWriter struct {
+	http.ResponseWriter
+}
+
+func (w noRangesWriter) WriteHeader(statusCode int) {
+	w.Header().Del("Accept-Ranges")
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w noRangesWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Check if any segment of the path starts with a dot.
+func containsDotfile(name string) bool {
+	for _, segment := range strings.Split(filepath.ToSlash(name), "/") {
+		if len(segment) > 1 && segment[0] == '.' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Check if any segment of the path refers to the parent directory.
+func containsUpPath(name string) bool {
+	for _, segment := range strings.Split(filepath.ToSlash(name), "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get an ETag from the size and the modification time, or from the content if the modification time is unknown.
+//
+// The ETag is strong, since If-Range only matches strong ETags.
+func getEtag(stat fs.FileInfo, content io.ReadSeeker) string {
+	if !stat.ModTime().IsZero() {
+		return `"` + strconv.FormatInt(stat.Size(), 16) + "-" + strconv.FormatInt(stat.ModTime().UnixNano(), 16) + `"`
+	}
+
+	// files from embed.FS have no modification time
+	h := fnv.New64a()
+	io.Copy(h, content)
+	content.Seek(0, io.SeekStart)
+	return `"` + strconv.FormatInt(stat.Size(), 16) + "-" + strconv.FormatUint(h.Sum64(), 16) + `"`
+}
+
+// Merge the user config with the default config.
+func createSendFileConfig(sendFileConfig []SendFileConfig) SendFileConfig {
+	// the default config
+	config := SendFileConfig{
+		acceptRangesBool: true,
+		cacheControlBool: true,
+		Dotfiles:         SendFileDotfilesIgnore,
+		etagBool:         true,
+		Headers:          map[string]string{},
+		lastModifiedBool: true,
+	}
+
+	// merge configs
+	if len(sendFileConfig) > 0 {
+		userConfig := sendFileConfig[0]
+
+		if b, ok := userConfig.AcceptRanges.(bool); ok {
+			config.acceptRangesBool = b
+		}
+		if b, ok := userConfig.CacheControl.(bool); ok {
+			config.cacheControlBool = b
+		}
+		if userConfig.Dotfiles != "" {
+			config.Dotfiles = userConfig.Dotfiles
+		}
+		if b, ok := userConfig.Etag.(bool); ok {
+			config.etagBool = b
+		}
+		if len(userConfig.Headers) > 0 {
+			config.Headers = userConfig.Headers
+		}
+		if userConfig.Immutable {
+			config.Immutable = userConfig.Immutable
+		}
+		if b, ok := userConfig.LastModified.(bool); ok {
+			config.lastModifiedBool = b
+		}
+		if userConfig.MaxAge > 0 {
+			config.MaxAge = userConfig.MaxAge
+		}
+		if userConfig.Root != nil {
+			config.Root = userConfig.Root
+		}
+		if userConfig.SetHeaders != nil {
+			config.SetHeaders = userConfig.SetHeaders
+		}
+	}
+
+	return config
+}
+
+// Resolve the path to a file system and the name of the file in it.
+func resolveFile(p string, root any) (fs.FS, string, error) {
+	// reject malicious paths
+	if containsUpPath(p) {
+		return nil, "", ErrSendFileForbidden
+	}
+
+	if root == nil {
+		if !filepath.IsAbs(p) {
+			return nil, "", errors.New("path must be absolute or specify root to res.SendFile")
+		}
+
+		p = filepath.Clean(p)
+		return os.DirFS(filepath.Dir(p)), filepath.Base(p), nil
+	}
+
+	var fsys fs.FS
+	if r, ok := root.(string); ok {
+		fsys = os.DirFS(r)
+	} else if r, ok := root.(fs.FS); ok {
+		fsys = r
+	} else {
+		return nil, "", errors.New("root should be a string or an fs.FS")
+	}
+
+	// names in fs.FS are slash-separated and unrooted
+	name := strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(p)), "/")
+	if name == "" {
+		name = "."
+	}
+
+	return fsys, name, nil
+}
+
+// Send the file at the path. The path should be absolute unless the root is set, then the file is confined in the root.
+//
+// Content-Type is set from the extension if it is not set. Conditional requests (If-None-Match, If-Modified-Since) and range requests (Range, If-Range) are handled.
+//
+// Return ErrSendFileNotFound if the file does not exist or is a directory, ErrSendFileForbidden if the path is not allowed, and other errors from reading the file.
+func (res *Response) SendFile(p string, sendFileConfig ...SendFileConfig) error {
+	// if end is already designated, this method should be a no-op
+	if res.end {
+		return nil
+	}
+
+	config := createSendFileConfig(sendFileConfig)
+
+	fsys, name, err := resolveFile(p, config.Root)
+	if err != nil {
+		return err
+	}
+
+	// without root, the whole path is checked
+	checked := name
+	if config.Root == nil {
+		checked = p
+	}
+	if containsDotfile(checked) {
+		switch config.Dotfiles {
+		case SendFileDotfilesAllow:
+		case SendFileDotfilesDeny:
+			return ErrSendFileForbidden
+		default:
+			return ErrSendFileNotFound
+		}
+	}
+
+	stat, err := fs.Stat(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			return ErrSendFileNotFound
+		}
+		return err
+	}
+	if stat.IsDir() {
+		return ErrSendFileNotFound
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		// read the whole file if it could not seek
+		b, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(b)
+	}
+
+	if config.cacheControlBool && res.native.Header().Get("Cache-Control") == "" {
+		cacheControl := "public, max-age=" + strconv.FormatInt(int64(config.MaxAge/time.Second), 10)
+		if config.Immutable {
+			cacheControl += ", immutable"
+		}
+		res.Set("Cache-Control", cacheControl)
+	}
+
+	modtime := time.Time{}
+	if config.lastModifiedBool {
+		modtime = stat.ModTime()
+	}
+
+	if config.etagBool {
+		res.Set("ETag", getEtag(stat, content))
+	}
+
+	if res.native.Header().Get("Content-Type") == "" {
+		res.Type(filepath.Ext(name))
+	}
+
+	for k, v := range config.Headers {
+		res.Set(k, v)
+	}
+
+	if config.SetHeaders != nil {
+		config.SetHeaders(res, name, stat)
+	}
+
+	r := res.req.Native
+	w := res.Writer()
+	if !config.acceptRangesBool {
+		r = r.Clone(r.Context())
+		r.Header.Del("Range")
+		r.Header.Del("If-Range")
+		w = noRangesWriter{w}
+	}
+
+	http.ServeContent(w, r, stat.Name(), modtime, content)
+	res.End()
+
+	return nil
+}
+
+// Encode a string as an RFC 5987 ext-value.
+func encodeExtValue(s string) string {
+	const attrChars = "!#$&+-.^_`|~"
+
+	var b strings.Builder
+	b.WriteString("UTF-8''")
+	for _, c := range []byte(s) {
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// Create a Content-Disposition header value for an attachment as per RFC 6266.
+//
+// Non-ASCII file names are sent with filename*, and filename is kept as an ASCII fallback.
+func contentDisposition(filename string) string {
+	if filename == "" {
+		return "attachment"
+	}
+
+	name := filepath.Base(filename)
+
+	fallback := []rune{}
+	isAscii := true
+	for _, c := range name {
+		if c < 0x20 || c > 0x7e {
+			fallback = append(fallback, '?')
+			isAscii = false
+		} else {
+			fallback = append(fallback, c)
+		}
+	}
+
+	quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(string(fallback))
+	value := `attachment; filename="` + quoted + `"`
+
+	// percent-encoded sequences in the name could be decoded by clients, send the exact name with filename*
+	if !isAscii || strings.Contains(name, "%") {
+		value += "; filename*=" + encodeExtValue(name)
+	}
+
+	return value
+}
+
+// Set Content-Disposition to attachment. If the filename is given, Content-Type is set from its extension.
+func (res *Response) Attachment(filename ...string) {
+	name := ""
+	if len(filename) > 0 {
+		name = filename[0]
+	}
+
+	if name != "" {
+		res.Type(filepath.Ext(name))
+	}
+	res.Set("Content-Disposition", contentDisposition(name))
+}
+
+// Send the file at the path as an attachment, the filename defaults to the base name of the path.
+//
+// It is the same as res.SendFile with Content-Disposition set.
+func (res *Response) Download(p string, filename string, sendFileConfig ...SendFileConfig) error {
+	// if end is already designated, this method should be a no-op
+	if res.end {
+		return nil
+	}
+
+	if filename == "" {
+		filename = filepath.Base(p)
+	}
+
+	res.Attachment(filename)
+	return res.SendFile(p, sendFileConfig...)
+}
@@ -0,0 +1,110 @@
+package expressgo
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSendFile(t *testing.T) {
+	files := fstest.MapFS{
+		"hello.txt":       {Data: []byte("hello world"), ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+		"embedded.txt":    {Data: []byte("no modification time")},
+		".env":            {Data: []byte("secret")},
+		".hidden/file.js": {Data: []byte("hidden")},
+	}
+
+	app := CreateServer()
+	app.Get("/files/*path", func(req *Request, res *Response, next *Next) {
+		config := SendFileConfig{Root: files, Dotfiles: req.Query["dotfiles"]}
+		if req.Query["ranges"] == "false" {
+			config.AcceptRanges = false
+		}
+
+		switch err := res.SendFile(req.Params["path"], config); err {
+		case nil:
+		case ErrSendFileForbidden:
+			res.Status(http.StatusForbidden).Send("forbidden")
+		case ErrSendFileNotFound:
+			res.Status(http.StatusNotFound).Send("not found")
+		default:
+			next.Err = err
+		}
+	})
+	app.Get("/escape", func(req *Request, res *Response, next *Next) {
+		if err := res.SendFile("../hello.txt", SendFileConfig{Root: files}); err == ErrSendFileForbidden {
+			res.Status(http.StatusForbidden).Send("forbidden")
+		}
+	})
+	app.Get("/relative", func(req *Request, res *Response, next *Next) {
+		if err := res.SendFile("hello.txt"); err != nil {
+			res.Status(http.StatusInternalServerError).Send("relative")
+		}
+	})
+
+	send := func(target string, header map[string]string) (*http.Response, string) {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		app.handler.ServeHTTP(w, r)
+		body, _ := io.ReadAll(w.Result().Body)
+		return w.Result(), string(body)
+	}
+
+	resp, body := send("/files/hello.txt", nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || body != "hello world" || resp.Header.Get("Content-Type") != "text/plain; charset=utf-8" || resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("expected 200 %q, got %d %q %v", "hello world", resp.StatusCode, body, resp.Header)
+	}
+	if etag == "" || etag[0] != '"' {
+		t.Errorf("expected a strong ETag, got %q", etag)
+	}
+	if resp.Header.Get("Last-Modified") != "Tue, 02 Jan 2024 03:04:05 GMT" || resp.Header.Get("Cache-Control") != "public, max-age=0" {
+		t.Errorf("expected caching headers, got %v", resp.Header)
+	}
+
+	if resp, body := send("/files/embedded.txt", nil); resp.StatusCode != http.StatusOK || body != "no modification time" || resp.Header.Get("ETag") == "" || resp.Header.Get("Last-Modified") != "" {
+		t.Errorf("expected an ETag from the content, got %d %q %v", resp.StatusCode, body, resp.Header)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		header map[string]string
+		status int
+		body   string
+	}{
+		{"if-none-match", "/files/hello.txt", map[string]string{"If-None-Match": etag}, http.StatusNotModified, ""},
+		{"if-modified-since", "/files/hello.txt", map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 03:04:05 GMT"}, http.StatusNotModified, ""},
+		{"range", "/files/hello.txt", map[string]string{"Range": "bytes=0-4"}, http.StatusPartialContent, "hello"},
+		{"suffix range", "/files/hello.txt", map[string]string{"Range": "bytes=-5"}, http.StatusPartialContent, "world"},
+		{"unsatisfiable range", "/files/hello.txt", map[string]string{"Range": "bytes=20-30"}, http.StatusRequestedRangeNotSatisfiable, ""},
+		{"if-range etag", "/files/hello.txt", map[string]string{"Range": "bytes=6-", "If-Range": etag}, http.StatusPartialContent, "world"},
+		{"if-range date", "/files/hello.txt", map[string]string{"Range": "bytes=6-", "If-Range": "Tue, 02 Jan 2024 03:04:05 GMT"}, http.StatusPartialContent, "world"},
+		{"if-range stale", "/files/hello.txt", map[string]string{"Range": "bytes=6-", "If-Range": `"stale"`}, http.StatusOK, "hello world"},
+		{"ranges disabled", "/files/hello.txt?ranges=false", map[string]string{"Range": "bytes=0-4"}, http.StatusOK, "hello world"},
+		{"missing", "/files/missing.txt", nil, http.StatusNotFound, "not found"},
+		{"dotfile ignored", "/files/.env", nil, http.StatusNotFound, "not found"},
+		{"dotfile directory ignored", "/files/.hidden/file.js", nil, http.StatusNotFound, "not found"},
+		{"dotfile denied", "/files/.env?dotfiles=deny", nil, http.StatusForbidden, "forbidden"},
+		{"dotfile allowed", "/files/.env?dotfiles=allow", nil, http.StatusOK, "secret"},
+		{"traversal", "/escape", nil, http.StatusForbidden, "forbidden"},
+		{"relative without root", "/relative", nil, http.StatusInternalServerError, "relative"},
+	}
+
+	for _, test := range tests {
+		resp, body := send(test.target, test.header)
+		if resp.StatusCode != test.status || (test.body != "" && body != test.body) {
+			t.Errorf("%s: expected %d %q, got %d %q", test.name, test.status, test.body, resp.StatusCode, body)
+		}
+	}
+
+	if resp, _ := send("/files/hello.txt?ranges=false", nil); resp.Header.Get("Accept-Ranges") != "" {
+		t.Errorf("expected no Accept-Ranges with ranges disabled, got %q", resp.Header.Get("Accept-Ranges"))
+	}
+}
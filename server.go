@@ -0,0 +1,325 @@
+package expressgo
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type ListenConfig struct {
+	// the host or address to bind, e.g., "127.0.0.1" or "::1", defaults to all interfaces, used by app.Listen and app.ListenTLS
+	Host string
+	// shut down gracefully on Signals, defaults to true
+	HandleSignals     any
+	handleSignalsBool bool
+	// signals for graceful shutdown, defaults to SIGINT and SIGTERM
+	Signals []os.Signal
+	// time to drain connections on signals before closing them, defaults to 10 seconds
+	ShutdownTimeout time.Duration
+}
+
+func createListenConfig(listenConfig []ListenConfig) ListenConfig {
+	// the default config
+	config := ListenConfig{
+		handleSignalsBool: true,
+		Signals:           []os.Signal{os.Interrupt, syscall.SIGTERM},
+		ShutdownTimeout:   10 * time.Second,
+	}
+
+	// merge configs
+	if len(listenConfig) > 0 {
+		userConfig := listenConfig[0]
+
+		if userConfig.Host != "" {
+			config.Host = userConfig.Host
+		}
+		if b, ok := userConfig.HandleSignals.(bool); ok {
+			config.handleSignalsBool = b
+		}
+		if len(userConfig.Signals) > 0 {
+			config.Signals = userConfig.Signals
+		}
+		if userConfig.ShutdownTimeout > 0 {
+			config.ShutdownTimeout = userConfig.ShutdownTimeout
+		}
+	}
+
+	return config
+}
+
+// A running server returned by app.Listen.
+type Server struct {
+	native   *http.Server
+	listener net.Listener
+	app      *App
+	// ensures the server is stopped once
+	once sync.Once
+	// closed after the server is stopped and shutdown hooks are run
+	done chan struct{}
+	// the error stopping the server other than shutdown
+	err error
+	// cancels contexts of requests once the server is closed, or the graceful shutdown runs out of time
+	cancel context.CancelCauseFunc
+}
+
+// Register a function to be called with the server after the app starts listening.
+func (app *App) OnListen(hook func(server *Server)) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	*app.listenHooks = append(*app.listenHooks, hook)
+}
+
+// Register a function to be called after a server of the app is stopped and its connections are drained or closed.
+func (app *App) OnShutdown(hook func()) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	*app.shutdownHooks = append(*app.shutdownHooks, hook)
+}
+
+// Listen to the port and serve HTTP in the background, the port is chosen by the system if it is 0.
+//
+// Return the running server, or the error if the port could not be listened to. Use server.Wait to block until the server is stopped.
+//
+// The server is shut down gracefully on SIGINT and SIGTERM by default, see ListenConfig.
+func (app *App) Listen(port int, listenConfig ...ListenConfig) (*Server, error) {
+	config := createListenConfig(listenConfig)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(config.Host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	return app.serve(listener, config, func(native *http.Server) error {
+		return native.Serve(listener)
+	}), nil
+}
+
+// Listen to the port and serve HTTPS in the background with the certificate and the key, HTTP/2 is negotiated with clients supporting it. See app.Listen.
+//
+// Return the error if the certificate or the key could not be loaded, or the port could not be listened to.
+func (app *App) ListenTLS(port int, certFile string, keyFile string, listenConfig ...ListenConfig) (*Server, error) {
+	config := createListenConfig(listenConfig)
+
+	// fail before serving if the key pair is invalid
+	if _, err := tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(config.Host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	return app.serve(listener, config, func(native *http.Server) error {
+		return native.ServeTLS(listener, certFile, keyFile)
+	}), nil
+}
+
+// Listen to the Unix domain socket at the path and serve HTTP in the background. See app.Listen.
+//
+// A stale socket file left at the path is removed, and the socket file is removed once the server is stopped.
+func (app *App) ListenUnix(path string, listenConfig ...ListenConfig) (*Server, error) {
+	config := createListenConfig(listenConfig)
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.serve(listener, config, func(native *http.Server) error {
+		return native.Serve(listener)
+	}), nil
+}
+
+// Serve HTTP with the listener in the background, e.g., a listener from systemd socket activation or a TLS listener. See app.Listen.
+//
+// The listener is closed once the server is stopped.
+func (app *App) ListenOn(listener net.Listener, listenConfig ...ListenConfig) *Server {
+	config := createListenConfig(listenConfig)
+
+	return app.serve(listener, config, func(native *http.Server) error {
+		return native.Serve(listener)
+	})
+}
+
+// Create the server of net/http with options from Config.
+func (app *App) createNativeServer() *http.Server {
+	app.mu.RLock()
+	defer app.mu.RUnlock()
+
+	native := &http.Server{
+		Handler:           app.handler,
+		ReadTimeout:       app.config.readTimeout,
+		ReadHeaderTimeout: app.config.readHeaderTimeout,
+		WriteTimeout:      app.config.writeTimeout,
+		IdleTimeout:       app.config.idleTimeout,
+		MaxHeaderBytes:    app.config.maxHeaderBytes,
+	}
+
+	if app.config.h2c {
+		protocols := &http.Protocols{}
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		native.Protocols = protocols
+	}
+
+	return native
+}
+
+// Serve with the listener in the background with the serve function, and run listen hooks.
+func (app *App) serve(listener net.Listener, config ListenConfig, serve func(native *http.Server) error) *Server {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	s := &Server{
+		native:   app.createNativeServer(),
+		listener: listener,
+		app:      app,
+		done:     make(chan struct{}),
+		cancel:   cancel,
+	}
+	// contexts of requests are derived from it, so they are cancelled once the server closes connections, see Server.close
+	s.native.BaseContext = func(net.Listener) context.Context {
+		return ctx
+	}
+
+	go func() {
+		if err := serve(s.native); !errors.Is(err, http.ErrServerClosed) {
+			s.stop(func() error {
+				s.err = err
+				return s.close()
+			})
+		}
+	}()
+
+	if config.handleSignalsBool {
+		go s.handleSignals(config.Signals, config.ShutdownTimeout)
+	}
+
+	log.Println("expressgo listens on: " + s.Addr().Network() + " " + s.Addr().String())
+
+	app.mu.RLock()
+	hooks := *app.listenHooks
+	app.mu.RUnlock()
+	for _, hook := range hooks {
+		hook(s)
+	}
+
+	return s
+}
+
+// Shut down the server gracefully on the first signal, waiting for connections up to the timeout.
+func (s *Server) handleSignals(signals []os.Signal, timeout time.Duration) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, signals...)
+	defer signal.Stop(c)
+
+	select {
+	case sig := <-c:
+		log.Println("expressgo shuts down on signal: " + sig.String())
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := s.Shutdown(ctx); err != nil {
+			log.Println("expressgo shuts down with error:", err)
+		}
+	case <-s.done:
+	}
+}
+
+// Stop the server once with the function, and run shutdown hooks.
+//
+// Return whether the server is stopped by this call, and the error from the function.
+func (s *Server) stop(f func() error) (bool, error) {
+	stopped := false
+	var err error
+
+	s.once.Do(func() {
+		stopped = true
+		err = f()
+
+		s.app.mu.RLock()
+		hooks := *s.app.shutdownHooks
+		s.app.mu.RUnlock()
+		for _, hook := range hooks {
+			hook()
+		}
+
+		close(s.done)
+	})
+
+	return stopped, err
+}
+
+// Get the address the server listens to.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Stop accepting connections, close idle keep-alive connections, and wait for active ones to finish, then run shutdown hooks.
+//
+// Requests in flight are served as usual while connections are drained. If the context is done first, their contexts are cancelled with http.ErrServerClosed as the cause before connections are closed, so callbacks watching req.Context().Done() could return early.
+//
+// If the context is done before connections are drained, remaining connections are closed and the error of the context is returned.
+//
+// If the server is being stopped by another call, it waits for that call to finish.
+func (s *Server) Shutdown(ctx context.Context) error {
+	stopped, err := s.stop(func() error {
+		if err := s.native.Shutdown(ctx); err != nil {
+			s.close()
+			return err
+		}
+		return nil
+	})
+	if stopped {
+		return err
+	}
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Cancel contexts of requests in flight with http.ErrServerClosed as the cause, and close all connections.
+func (s *Server) close() error {
+	s.cancel(http.ErrServerClosed)
+	return s.native.Close()
+}
+
+// Close the server and all connections immediately, then run shutdown hooks.
+//
+// Contexts of requests in flight are cancelled with http.ErrServerClosed as the cause.
+func (s *Server) Close() error {
+	stopped, err := s.stop(s.close)
+	if !stopped {
+		<-s.done
+	}
+	return err
+}
+
+// Block until the server is stopped and shutdown hooks are run.
+//
+// Return the error stopping the server, nil if it is stopped by Shutdown, Close, or signals.
+func (s *Server) Wait() error {
+	<-s.done
+	return s.err
+}
@@ -0,0 +1,166 @@
+package expressgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// In-flight requests should complete during graceful shutdown, and hooks should be run.
+func TestServerShutdown(t *testing.T) {
+	app := CreateServer()
+
+	started := make(chan struct{})
+	// the chain goes on after the first callback, since contexts are not cancelled while connections are drained
+	app.Get("/slow", func(req *Request, res *Response, next *Next) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		next.Next = true
+	}, func(req *Request, res *Response, next *Next) {
+		res.Send("done")
+	})
+
+	listened := false
+	shutdown := false
+	app.OnListen(func(server *Server) { listened = true })
+	app.OnShutdown(func() { shutdown = true })
+
+	server, err := app.Listen(0, ListenConfig{HandleSignals: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !listened {
+		t.Error("listen hooks are not run")
+	}
+
+	url := "http://" + server.Addr().String() + "/slow"
+
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		result <- strconv.Itoa(resp.StatusCode) + " " + string(body)
+	}()
+
+	<-started
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !shutdown {
+		t.Error("shutdown hooks are not run before Shutdown returns")
+	}
+	if got := <-result; got != "200 done" {
+		t.Errorf("in-flight request: got %q, want %q", got, "200 done")
+	}
+	if err := server.Wait(); err != nil {
+		t.Errorf("wait: got %v, want nil", err)
+	}
+
+	// the listener is closed
+	if _, err := http.Get(url); err == nil {
+		t.Error("requests are accepted after shutdown")
+	}
+}
+
+// Contexts of requests in flight should be cancelled when graceful shutdown runs out of time.
+func TestServerShutdownCancelsRequests(t *testing.T) {
+	app := CreateServer()
+
+	started := make(chan struct{})
+	cause := make(chan error, 1)
+	app.Get("/wait", func(req *Request, res *Response, next *Next) {
+		close(started)
+		select {
+		case <-req.Context().Done():
+			cause <- context.Cause(req.Context())
+		case <-time.After(5 * time.Second):
+			cause <- nil
+		}
+		res.Send("done")
+	})
+
+	server, err := app.Listen(0, ListenConfig{Host: "127.0.0.1", HandleSignals: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go http.Get("http://" + server.Addr().String() + "/wait")
+
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("shutdown: got %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := <-cause; got != http.ErrServerClosed {
+		t.Errorf("cause: got %v, want %v", got, http.ErrServerClosed)
+	}
+}
+
+// HTTP/2 without TLS should be served with H2C, and Unix domain sockets should be served.
+func TestServerH2CAndUnix(t *testing.T) {
+	app := CreateServer(Config{H2C: true, ReadHeaderTimeout: time.Second})
+	app.Get("/", func(req *Request, res *Response, next *Next) {
+		res.Send(req.Native.Proto)
+	})
+
+	server, err := app.Listen(0, ListenConfig{Host: "127.0.0.1", HandleSignals: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	protocols := &http.Protocols{}
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+	resp, err := client.Get("http://" + server.Addr().String() + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "HTTP/2.0" {
+		t.Errorf("h2c: got %q, want %q", body, "HTTP/2.0")
+	}
+
+	path := filepath.Join(t.TempDir(), "app.sock")
+	unixServer, err := app.ListenUnix(path, ListenConfig{HandleSignals: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network string, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err = client.Get("http://unix/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "HTTP/1.1" {
+		t.Errorf("unix: got %q, want %q", body, "HTTP/1.1")
+	}
+
+	if err := unixServer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("the socket file is not removed after the server is stopped")
+	}
+}
@@ -0,0 +1,356 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/cookieparser"
+)
+
+const (
+	UnsetKeep    = "keep"
+	UnsetDestroy = "destroy"
+)
+
+type SessionConfig struct {
+	// string or []string, the first one is used for signing and all are tried for unsigning
+	Secret      any
+	secretSlice []string
+	// options of the session id cookie, defaults to {Path: "/", HttpOnly: true}, MaxAge sets the lifetime of sessions
+	Cookie *expressgo.CookieOptions
+	// generate session ids, defaults to 24 random bytes encoded in base64url
+	Genid func(req *expressgo.Request) string
+	// name of the session id cookie, defaults to "connect.sid"
+	Name string
+	// save the session on every request even if it is not modified, defaults to true
+	Resave     any
+	resaveBool bool
+	// reset the expiration of the cookie on every response, defaults to false
+	Rolling bool
+	// save new sessions which are not modified, defaults to true
+	SaveUninitialized     any
+	saveUninitializedBool bool
+	// defaults to a memory store
+	Store Store
+	// what to do with the session in the store when req.Session is set to nil, "keep" or "destroy", defaults to "keep"
+	Unset string
+}
+
+// The session of a request, set to req.Session by the session middleware.
+type Session struct {
+	id      string
+	values  map[string]any
+	expires time.Time
+	req     *expressgo.Request
+	config  *SessionConfig
+	// the id and the hash of the session when it was loaded
+	originalId   string
+	originalHash string
+	// the hash of the session when it was last saved
+	savedHash string
+	touched   bool
+}
+
+// Generate a session id of 24 random bytes encoded in base64url.
+func generateId(req *expressgo.Request) string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Hash the values to detect modifications.
+func hash(values map[string]any) string {
+	b, err := json.Marshal(values)
+	if err != nil {
+		// values which could not be encoded are always treated as modified
+		return ""
+	}
+
+	sum := sha1.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Session) Id() string {
+	return s.id
+}
+
+func (s *Session) Get(key string) any {
+	return s.values[key]
+}
+
+func (s *Session) Set(key string, value any) {
+	s.values[key] = value
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.values, key)
+}
+
+// Reset the expiration with the MaxAge of the cookie.
+func (s *Session) Touch() {
+	if s.config.Cookie.MaxAge > 0 {
+		s.expires = time.Now().Add(s.config.Cookie.MaxAge)
+	}
+}
+
+// Get the expiration of the session, zero if it lasts until the browser is closed.
+func (s *Session) Expires() time.Time {
+	return s.expires
+}
+
+func (s *Session) data() *Data {
+	return &Data{Values: s.values, Expires: s.expires}
+}
+
+// Save the session to the store. It is done automatically before headers are sent if the session is modified.
+func (s *Session) Save() error {
+	if err := s.config.Store.Set(s.id, s.data()); err != nil {
+		return err
+	}
+
+	s.savedHash = hash(s.values)
+	return nil
+}
+
+// Reload the values of the session from the store.
+func (s *Session) Reload() error {
+	data, err := s.config.Store.Get(s.id)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return errors.New("failed to load session")
+	}
+
+	s.values = data.Values
+	s.expires = data.Expires
+	return nil
+}
+
+// Destroy the session in the store and unset req.Session.
+func (s *Session) Destroy() error {
+	if s.req.Session == s {
+		s.req.Session = nil
+	}
+
+	return s.config.Store.Destroy(s.id)
+}
+
+// Destroy the session in the store and replace it with a new empty one with a new id.
+func (s *Session) Regenerate() error {
+	if err := s.config.Store.Destroy(s.id); err != nil {
+		return err
+	}
+
+	s.id = s.config.Genid(s.req)
+	s.values = map[string]any{}
+	s.expires = time.Time{}
+	s.Touch()
+	s.savedHash = ""
+	s.req.Session = s
+	return nil
+}
+
+// Check if the session is modified since it was loaded.
+func (s *Session) isModified() bool {
+	return s.originalId != s.id || s.originalHash != hash(s.values)
+}
+
+// Check if the session is saved in the store.
+func (s *Session) isSaved() bool {
+	return s.originalId == s.id && s.savedHash != "" && s.savedHash == hash(s.values)
+}
+
+// Get the session id from the signed cookie, empty if it is not found or the signature is invalid.
+func getCookieId(req *expressgo.Request, name string, secrets []string) string {
+	// cookies might be split into multiple headers in HTTP/2
+	cookies := cookieparser.Parse(strings.Join(req.Native.Header.Values("Cookie"), "; "), nil)
+
+	value, ok := cookies[name]
+	if !ok || !strings.HasPrefix(value, "s:") {
+		return ""
+	}
+
+	id, ok := expressgo.UnsignCookie(value[2:], secrets...)
+	if !ok {
+		return ""
+	}
+
+	return id
+}
+
+// Create the session middleware, which loads the session by the id in the cookie to req.Session, or creates a new one.
+//
+// The session is saved right before headers are sent if it is modified, and the cookie is set if the session is new or modified, or Rolling is set. Errors of the store are passed to error handlers, which respond instead.
+func Use(sessionConfig SessionConfig) expressgo.Callback {
+	// the default config
+	config := SessionConfig{
+		Cookie:                &expressgo.CookieOptions{Path: "/", HttpOnly: true},
+		Genid:                 generateId,
+		Name:                  "connect.sid",
+		resaveBool:            true,
+		saveUninitializedBool: true,
+		Unset:                 UnsetKeep,
+	}
+
+	// merge configs
+	if s, ok := sessionConfig.Secret.(string); ok && s != "" {
+		config.secretSlice = []string{s}
+	} else if ss, ok := sessionConfig.Secret.([]string); ok && len(ss) > 0 {
+		config.secretSlice = ss
+	} else {
+		panic(errors.New("secret is required for sessions"))
+	}
+	if sessionConfig.Cookie != nil {
+		cookie := *sessionConfig.Cookie
+		if cookie.Path == "" {
+			cookie.Path = "/"
+		}
+		config.Cookie = &cookie
+	}
+	if sessionConfig.Genid != nil {
+		config.Genid = sessionConfig.Genid
+	}
+	if sessionConfig.Name != "" {
+		config.Name = sessionConfig.Name
+	}
+	if b, ok := sessionConfig.Resave.(bool); ok {
+		config.resaveBool = b
+	}
+	if sessionConfig.Rolling {
+		config.Rolling = sessionConfig.Rolling
+	}
+	if b, ok := sessionConfig.SaveUninitialized.(bool); ok {
+		config.saveUninitializedBool = b
+	}
+	if sessionConfig.Store != nil {
+		config.Store = sessionConfig.Store
+	} else {
+		config.Store = CreateMemoryStore()
+	}
+	if sessionConfig.Unset != "" {
+		config.Unset = sessionConfig.Unset
+	}
+
+	// create session middleware
+	session := func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		// proceed to the next callback
+		next.Next = true
+		next.Route = true
+
+		// the session is set by session middlewares before
+		if req.Session != nil {
+			return
+		}
+
+		// the cookie would not be sent for paths out of its path
+		path := strings.SplitN(req.OriginalUrl, "?", 2)[0]
+		if !strings.HasPrefix(path, config.Cookie.Path) {
+			return
+		}
+
+		cookieId := getCookieId(req, config.Name, config.secretSlice)
+
+		var s *Session
+		if cookieId != "" {
+			data, err := config.Store.Get(cookieId)
+			if err != nil {
+				next.Err = err
+				return
+			}
+
+			if data != nil {
+				s = &Session{
+					id:           cookieId,
+					values:       data.Values,
+					expires:      data.Expires,
+					req:          req,
+					config:       &config,
+					originalId:   cookieId,
+					originalHash: hash(data.Values),
+				}
+				// with resave, the session is never treated as saved
+				if !config.resaveBool {
+					s.savedHash = s.originalHash
+				}
+			}
+		}
+
+		// create a new session
+		if s == nil {
+			s = &Session{
+				id:     config.Genid(req),
+				values: map[string]any{},
+				req:    req,
+				config: &config,
+			}
+			s.Touch()
+			s.originalId = s.id
+			s.originalHash = hash(s.values)
+		}
+
+		req.Session = s
+
+		// check if the cookie should be set
+		shouldSetCookie := func() bool {
+			if cookieId != s.id {
+				return config.saveUninitializedBool || s.isModified()
+			}
+			return config.Rolling || (!s.expires.IsZero() && s.isModified())
+		}
+
+		// check if the session should be saved
+		shouldSave := func() bool {
+			if !config.saveUninitializedBool && s.savedHash == "" && cookieId != s.id {
+				return s.isModified()
+			}
+			return !s.isSaved()
+		}
+
+		// the session is saved right before headers are sent, so a failure could still be responded by error handlers
+		res.OnHeaders(func() error {
+			if req.Session != s {
+				// the session is unset
+				if req.Session == nil && config.Unset == UnsetDestroy {
+					return config.Store.Destroy(s.id)
+				}
+				return nil
+			}
+
+			if !s.touched {
+				s.Touch()
+				s.touched = true
+			}
+
+			if shouldSave() {
+				if err := s.Save(); err != nil {
+					return err
+				}
+			} else if cookieId == s.id {
+				if err := config.Store.Touch(s.id, s.data()); err != nil {
+					return err
+				}
+			}
+
+			// only send secure cookies via https
+			if !shouldSetCookie() || (config.Cookie.Secure && !req.Secure()) {
+				return nil
+			}
+
+			options := *config.Cookie
+			options.Expires = s.expires
+			options.MaxAge = 0
+			options.Signed = false
+			return res.Cookie(config.Name, "s:"+expressgo.SignCookie(s.id, config.secretSlice[0]), options)
+		})
+	}
+
+	return session
+}
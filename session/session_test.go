@@ -0,0 +1,200 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/expressgotest"
+)
+
+// A memory store counting saves, which fails to save if fail is set.
+type testStore struct {
+	*MemoryStore
+	saves int
+	fail  bool
+}
+
+func (store *testStore) Set(id string, data *Data) error {
+	if store.fail {
+		return errors.New("500: save failed")
+	}
+	store.saves += 1
+	return store.MemoryStore.Set(id, data)
+}
+
+// Create an app counting views in sessions, with routes to regenerate and destroy sessions.
+func createApp(config SessionConfig) expressgo.App {
+	app := expressgo.CreateServer()
+	app.UseGlobal(Use(config))
+
+	app.Get("/views", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		views, _ := req.Session.Get("views").(float64)
+		req.Session.Set("views", views+1)
+		res.Send(fmt.Sprintf("%s %v", req.Session.Id(), views+1))
+	})
+	app.Get("/read", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Send(fmt.Sprintf("%s %v", req.Session.Id(), req.Session.Get("views")))
+	})
+	app.Get("/regenerate", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		next.Err = req.Session.Regenerate()
+		res.Send(req.Session.Id())
+	})
+	app.Get("/destroy", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		next.Err = req.Session.Destroy()
+		res.Send("destroyed")
+	})
+
+	return app
+}
+
+// Get the session id and views from the body.
+func parseBody(body string) (string, string) {
+	id, views, _ := strings.Cut(body, " ")
+	return id, views
+}
+
+func TestSession(t *testing.T) {
+	store := &testStore{MemoryStore: CreateMemoryStore()}
+	app := createApp(SessionConfig{Secret: []string{"new", "keyboard cat"}, Store: store})
+	agent := expressgotest.New(&app)
+
+	// a new session is created with a signed cookie
+	res := agent.Get("/views").Expect(t).Status(200).Header("Set-Cookie", regexp.MustCompile(`^connect\.sid=s%3A.+; Path=/; HttpOnly$`))
+	id, views := parseBody(res.Text)
+	if views != "1" {
+		t.Errorf("expected 1 view, got %q", views)
+	}
+
+	cookie, _ := url.QueryUnescape(strings.TrimPrefix(strings.SplitN(res.Native.Header.Get("Set-Cookie"), ";", 2)[0], "connect.sid="))
+	if signed, ok := expressgo.UnsignCookie(strings.TrimPrefix(cookie, "s:"), "new"); !ok || signed != id {
+		t.Errorf("expected the id signed with the first secret, got %q %v", signed, ok)
+	}
+
+	// the session is loaded by the cookie
+	if _, views := parseBody(agent.Get("/views").Expect(t).Status(200).Text); views != "2" {
+		t.Errorf("expected 2 views, got %q", views)
+	}
+
+	// cookies signed with other secrets are accepted, and forged cookies are ignored
+	signed := "s:" + expressgo.SignCookie(id, "keyboard cat")
+	agent2 := expressgotest.New(&app)
+	if _, views := parseBody(agent2.Get("/read").Set("Cookie", "connect.sid="+url.QueryEscape(signed)).Expect(t).Text); views != "2" {
+		t.Errorf("expected the session loaded with the old secret, got %q", views)
+	}
+	if newId, views := parseBody(agent2.Get("/read").Set("Cookie", "connect.sid="+url.QueryEscape("s:"+expressgo.SignCookie(id, "forged"))).Expect(t).Text); newId == id || views != "<nil>" {
+		t.Errorf("expected a new session for the forged cookie, got %q %q", newId, views)
+	}
+
+	// a regenerated session has a new id and the old one is destroyed
+	newId := agent.Get("/regenerate").Expect(t).Status(200).Header("Set-Cookie", regexp.MustCompile(`^connect\.sid=`)).Text
+	if newId == id {
+		t.Error("expected a new id")
+	}
+	if data, _ := store.Get(id); data != nil {
+		t.Errorf("expected the old session destroyed, got %v", data)
+	}
+	if readId, views := parseBody(agent.Get("/read").Expect(t).Text); readId != newId || views != "<nil>" {
+		t.Errorf("expected the regenerated session, got %q %q", readId, views)
+	}
+
+	// a destroyed session is removed from the store
+	agent.Get("/destroy").Expect(t).Status(200).NoHeader("Set-Cookie")
+	if data, _ := store.Get(newId); data != nil {
+		t.Errorf("expected the session destroyed, got %v", data)
+	}
+	if readId, _ := parseBody(agent.Get("/read").Expect(t).Text); readId == newId {
+		t.Error("expected a new session after destroying")
+	}
+}
+
+func TestSessionSaving(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SessionConfig
+		saves   int
+		cookies int
+	}{
+		// the default resaves sessions on every request, and sets cookies for new sessions
+		{"default", SessionConfig{}, 3, 1},
+		// unmodified sessions are not saved
+		{"no resave", SessionConfig{Resave: false}, 1, 1},
+		// cookies are set on every response
+		{"rolling", SessionConfig{Resave: false, Rolling: true}, 1, 3},
+		// new sessions are not saved until they are modified
+		{"no save uninitialized", SessionConfig{Resave: false, SaveUninitialized: false}, 0, 0},
+		// sessions with expiration are touched, and cookies are sent with the new expiration
+		{"max age", SessionConfig{Resave: false, Cookie: &expressgo.CookieOptions{MaxAge: time.Hour}}, 1, 1},
+	}
+
+	for _, test := range tests {
+		store := &testStore{MemoryStore: CreateMemoryStore()}
+		test.config.Secret = "keyboard cat"
+		test.config.Store = store
+		app := createApp(test.config)
+		agent := expressgotest.New(&app)
+
+		cookies := 0
+		for i := 0; i < 3; i++ {
+			if agent.Get("/read").Expect(t).Native.Header.Get("Set-Cookie") != "" {
+				cookies += 1
+			}
+		}
+
+		if store.saves != test.saves || cookies != test.cookies {
+			t.Errorf("%s: expected %d saves and %d cookies, got %d and %d", test.name, test.saves, test.cookies, store.saves, cookies)
+		}
+	}
+}
+
+func TestSessionSaveError(t *testing.T) {
+	store := &testStore{MemoryStore: CreateMemoryStore(), fail: true}
+	app := createApp(SessionConfig{Secret: "keyboard cat", Store: store})
+	agent := expressgotest.New(&app)
+
+	// without error handlers, 500 is sent
+	agent.Get("/views").Expect(t).Status(500).Body("").NoHeader("Set-Cookie")
+
+	// error handlers respond instead of the failed response
+	app.UseGlobalError(func(err error, req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Status(http.StatusServiceUnavailable).Send(err.Error())
+	})
+	agent.Get("/views").Expect(t).Status(503).Body("500: save failed").NoHeader("Set-Cookie")
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := CreateMemoryStore(MemoryStoreConfig{CheckPeriod: time.Millisecond})
+
+	store.Set("expired", &Data{Values: map[string]any{"a": 1.0}, Expires: time.Now().Add(time.Millisecond)})
+	store.Set("valid", &Data{Values: map[string]any{"a": 2.0}})
+
+	if data, _ := store.Get("valid"); data == nil || data.Values["a"] != 2.0 {
+		t.Errorf("expected the valid session, got %v", data)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	// expired sessions are removed when sessions are saved
+	store.Set("other", &Data{Values: map[string]any{}})
+	if _, ok := store.sessions["expired"]; ok {
+		t.Error("expected the expired session removed")
+	}
+	if all, _ := store.All(); len(all) != 2 {
+		t.Errorf("expected 2 sessions, got %v", all)
+	}
+
+	store.Set("expired", &Data{Values: map[string]any{}, Expires: time.Now().Add(time.Millisecond)})
+	time.Sleep(5 * time.Millisecond)
+
+	// expired sessions are removed when sessions are read as well
+	store.Get("valid")
+	if _, ok := store.sessions["expired"]; ok {
+		t.Error("expected the expired session removed on reading")
+	}
+}
@@ -0,0 +1,406 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The data of a session kept by stores.
+type Data struct {
+	Values map[string]any `json:"values"`
+	// zero if the session lasts until the browser is closed
+	Expires time.Time `json:"expires"`
+}
+
+// Check if the session has expired.
+func (data *Data) expired(now time.Time) bool {
+	return !data.Expires.IsZero() && !data.Expires.After(now)
+}
+
+// Stores keep sessions by their ids. They should be safe for concurrent use.
+type Store interface {
+	// Get the session of the id. Return nil without error if it is not found or has expired.
+	Get(id string) (*Data, error)
+	// Set the session of the id.
+	Set(id string, data *Data) error
+	// Destroy the session of the id.
+	Destroy(id string) error
+	// Reset the expiration of the session of the id.
+	Touch(id string, data *Data) error
+	// Get all sessions which have not expired.
+	All() (map[string]*Data, error)
+	// Destroy all sessions.
+	Clear() error
+}
+
+type MemoryStoreConfig struct {
+	// the interval of removing expired sessions, defaults to 1 minute
+	CheckPeriod time.Duration
+	// the lifetime of sessions without expiration, zero for keeping them until they are destroyed
+	Ttl time.Duration
+}
+
+// Keep sessions in memory. Sessions are lost on restart and not shared between processes.
+type MemoryStore struct {
+	mu       sync.Mutex
+	config   MemoryStoreConfig
+	sessions map[string][]byte
+	expires  map[string]time.Time
+	// the time expired sessions were last removed
+	swept time.Time
+}
+
+// Create a memory store, which removes expired sessions when sessions are read or saved, at most once every check period.
+//
+// No goroutine is started, so stores dropped by the app are garbage collected.
+func CreateMemoryStore(memoryStoreConfig ...MemoryStoreConfig) *MemoryStore {
+	// the default config
+	config := MemoryStoreConfig{
+		CheckPeriod: time.Minute,
+	}
+
+	// merge configs
+	if len(memoryStoreConfig) > 0 {
+		userConfig := memoryStoreConfig[0]
+
+		if userConfig.CheckPeriod > 0 {
+			config.CheckPeriod = userConfig.CheckPeriod
+		}
+		if userConfig.Ttl > 0 {
+			config.Ttl = userConfig.Ttl
+		}
+	}
+
+	return &MemoryStore{
+		config:   config,
+		sessions: map[string][]byte{},
+		expires:  map[string]time.Time{},
+		swept:    time.Now(),
+	}
+}
+
+// Remove expired sessions if the check period has passed since they were last removed. The lock should be held.
+func (store *MemoryStore) sweep(now time.Time) {
+	if now.Sub(store.swept) < store.config.CheckPeriod {
+		return
+	}
+	store.swept = now
+
+	for id, expires := range store.expires {
+		if !expires.IsZero() && !expires.After(now) {
+			delete(store.sessions, id)
+			delete(store.expires, id)
+		}
+	}
+}
+
+// Get the expiration of the session, sessions without expiration expire after Ttl if it is set.
+func (store *MemoryStore) expiration(data *Data) time.Time {
+	if data.Expires.IsZero() && store.config.Ttl > 0 {
+		return time.Now().Add(store.config.Ttl)
+	}
+
+	return data.Expires
+}
+
+// Get the session if it exists and has not expired. The lock should be held.
+func (store *MemoryStore) get(id string, now time.Time) (*Data, error) {
+	b, ok := store.sessions[id]
+	if !ok {
+		return nil, nil
+	}
+
+	if expires := store.expires[id]; !expires.IsZero() && !expires.After(now) {
+		delete(store.sessions, id)
+		delete(store.expires, id)
+		return nil, nil
+	}
+
+	data := &Data{}
+	if err := json.Unmarshal(b, data); err != nil {
+		return nil, err
+	}
+	if data.Values == nil {
+		data.Values = map[string]any{}
+	}
+
+	return data, nil
+}
+
+func (store *MemoryStore) Get(id string) (*Data, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	// stores only read by requests without changes to sessions are swept as well
+	now := time.Now()
+	store.sweep(now)
+
+	return store.get(id, now)
+}
+
+func (store *MemoryStore) Set(id string, data *Data) error {
+	// values are kept as JSON, so later changes to them are not reflected in the store
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.sweep(time.Now())
+	store.sessions[id] = b
+	store.expires[id] = store.expiration(data)
+	return nil
+}
+
+func (store *MemoryStore) Destroy(id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	delete(store.sessions, id)
+	delete(store.expires, id)
+	return nil
+}
+
+func (store *MemoryStore) Touch(id string, data *Data) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	store.sweep(now)
+
+	current, err := store.get(id, now)
+	if err != nil || current == nil {
+		return err
+	}
+
+	current.Expires = data.Expires
+	b, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	store.sessions[id] = b
+	store.expires[id] = store.expiration(current)
+	return nil
+}
+
+func (store *MemoryStore) All() (map[string]*Data, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	all := map[string]*Data{}
+	for id := range store.sessions {
+		data, err := store.get(id, now)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			all[id] = data
+		}
+	}
+
+	return all, nil
+}
+
+func (store *MemoryStore) Clear() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.sessions = map[string][]byte{}
+	store.expires = map[string]time.Time{}
+	return nil
+}
+
+var ErrInvalidId = errors.New("invalid session id")
+
+// ids are used as file names, only characters from base64url are allowed
+var validId = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Keep sessions as JSON files in a directory, one file per session. Expired sessions are removed when they are read.
+type FileStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// Create a file store in the directory, which is created if it does not exist.
+func CreateFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileStore{dir: dir}, nil
+}
+
+// Get the file path of the session.
+func (store *FileStore) file(id string) (string, error) {
+	if !validId.MatchString(id) {
+		return "", ErrInvalidId
+	}
+
+	return filepath.Join(store.dir, id+".json"), nil
+}
+
+// Read the session if it exists and has not expired. The lock should be held.
+func (store *FileStore) read(id string, now time.Time) (*Data, error) {
+	file, err := store.file(id)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	data := &Data{}
+	if err := json.Unmarshal(b, data); err != nil {
+		return nil, err
+	}
+
+	if data.expired(now) {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if data.Values == nil {
+		data.Values = map[string]any{}
+	}
+
+	return data, nil
+}
+
+// Write the session to a temporary file first, so a session file is never partially written. The lock should be held.
+func (store *FileStore) write(id string, data *Data) error {
+	file, err := store.file(id)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(store.dir, id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+func (store *FileStore) Get(id string) (*Data, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.read(id, time.Now())
+}
+
+func (store *FileStore) Set(id string, data *Data) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	return store.write(id, data)
+}
+
+func (store *FileStore) Destroy(id string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	file, err := store.file(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (store *FileStore) Touch(id string, data *Data) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	current, err := store.read(id, time.Now())
+	if err != nil || current == nil {
+		return err
+	}
+
+	current.Expires = data.Expires
+	return store.write(id, current)
+}
+
+func (store *FileStore) All() (map[string]*Data, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	all := map[string]*Data{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() || !validId.MatchString(id) {
+			continue
+		}
+
+		data, err := store.read(id, now)
+		if err != nil {
+			return nil, err
+		}
+		if data != nil {
+			all[id] = data
+		}
+	}
+
+	return all, nil
+}
+
+func (store *FileStore) Clear() error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	entries, err := os.ReadDir(store.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() || !validId.MatchString(id) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(store.dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package static
+
+import (
+	"errors"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Eandalf/expressgo"
+)
+
+var ErrForbidden = expressgo.ErrSendFileForbidden
+var ErrNotFound = expressgo.ErrSendFileNotFound
+
+const (
+	DotfilesAllow  = expressgo.SendFileDotfilesAllow
+	DotfilesDeny   = expressgo.SendFileDotfilesDeny
+	DotfilesIgnore = expressgo.SendFileDotfilesIgnore
+)
+
+type SetHeaders = expressgo.SendFileSetHeaders
+
+type StaticConfig struct {
+	AcceptRanges     any
+	acceptRangesBool bool
+	CacheControl     any
+	cacheControlBool bool
+	Dotfiles         string
+	Etag             any
+	etagBool         bool
+	Extensions       []string
+	Fallthrough      any
+	fallthroughBool  bool
+	Immutable        bool
+	Index            any
+	indexSlice       []string
+	LastModified     any
+	lastModifiedBool bool
+	MaxAge           time.Duration
+	Redirect         any
+	redirectBool     bool
+	SetHeaders       SetHeaders
+	// name of the wildcard param holding the file path
+	Param string
+}
+
+// Check if any segment of the path starts with a dot.
+func containsDotfile(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if len(segment) > 1 && segment[0] == '.' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Get the path of the file relative to root.
+//
+// The path is read from the wildcard param, or the original path without the base url if the param is not found.
+func getPath(req *expressgo.Request, param string) string {
+	if p, ok := req.Params[param]; ok {
+		return p
+	}
+
+	// the path matched by ServeMux might be rewritten, use the original one
+	p := strings.SplitN(req.OriginalUrl, "?", 2)[0]
+	p = strings.TrimPrefix(p, req.BaseUrl)
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		p = unescaped
+	}
+
+	return strings.TrimPrefix(p, "/")
+}
+
+// Send the file with caching headers, conditional requests, and ranges handled by res.SendFile.
+func (config *StaticConfig) sendFile(res *expressgo.Response, fsys fs.FS, name string) error {
+	return res.SendFile(name, expressgo.SendFileConfig{
+		AcceptRanges: config.acceptRangesBool,
+		CacheControl: config.cacheControlBool,
+		// dotfiles are checked before finding the file
+		Dotfiles:     DotfilesAllow,
+		Etag:         config.etagBool,
+		Immutable:    config.Immutable,
+		LastModified: config.lastModifiedBool,
+		MaxAge:       config.MaxAge,
+		Root:         fsys,
+		SetHeaders:   config.SetHeaders,
+	})
+}
+
+// Redirect a request for a directory to the path with a trailing slash.
+func redirect(req *expressgo.Request, res *expressgo.Response) {
+	location := strings.SplitN(req.OriginalUrl, "?", 2)
+	location[0] += "/"
+	l := strings.Join(location, "?")
+
+	res.Set("Location", l)
+	res.Set("Content-Type", "text/html; charset=utf-8")
+	res.Set("Content-Security-Policy", "default-src 'none'")
+	res.Set("X-Content-Type-Options", "nosniff")
+	res.Status(http.StatusMovedPermanently).Send("Redirecting to " + html.EscapeString(l))
+}
+
+// Find and send the file for the request.
+func (config *StaticConfig) serve(req *expressgo.Request, res *expressgo.Response, fsys fs.FS) error {
+	name := getPath(req, config.Param)
+	// the path matched by ServeMux might be rewritten with a trailing slash, check the original one
+	hasTrailingSlash := strings.HasSuffix(strings.SplitN(req.OriginalUrl, "?", 2)[0], "/")
+
+	// reject malicious paths
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return ErrForbidden
+		}
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	if containsDotfile(name) {
+		switch config.Dotfiles {
+		case DotfilesAllow:
+		case DotfilesDeny:
+			return ErrForbidden
+		default:
+			return ErrNotFound
+		}
+	}
+
+	stat, err := fs.Stat(fsys, name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		// try extensions if the file is not found
+		if !hasTrailingSlash {
+			for _, ext := range config.Extensions {
+				s, err := fs.Stat(fsys, name+"."+strings.TrimPrefix(ext, "."))
+				if err == nil && !s.IsDir() {
+					return config.sendFile(res, fsys, name+"."+strings.TrimPrefix(ext, "."))
+				}
+			}
+		}
+
+		return ErrNotFound
+	}
+
+	if stat.IsDir() {
+		if !hasTrailingSlash {
+			if config.redirectBool {
+				redirect(req, res)
+				return nil
+			}
+			return ErrNotFound
+		}
+
+		for _, index := range config.indexSlice {
+			s, err := fs.Stat(fsys, path.Join(name, index))
+			if err == nil && !s.IsDir() {
+				return config.sendFile(res, fsys, path.Join(name, index))
+			}
+		}
+
+		return ErrNotFound
+	}
+
+	// a file could not be requested as a directory
+	if hasTrailingSlash {
+		return ErrNotFound
+	}
+
+	return config.sendFile(res, fsys, name)
+}
+
+// Serve static files from the root directory.
+//
+// The callback is meant to be registered on a path ending with a wildcard, e.g., app.Get("/public/*path", static.Serve("./public")).
+func Serve(root string, staticConfig ...StaticConfig) expressgo.Callback {
+	return ServeFS(os.DirFS(root), staticConfig...)
+}
+
+// Serve static files from the file system, e.g., embed.FS.
+//
+// The callback is meant to be registered on a path ending with a wildcard, e.g., app.Get("/assets/*path", static.ServeFS(assets)).
+func ServeFS(fsys fs.FS, staticConfig ...StaticConfig) expressgo.Callback {
+	// the default config
+	config := StaticConfig{
+		acceptRangesBool: true,
+		cacheControlBool: true,
+		Dotfiles:         DotfilesIgnore,
+		etagBool:         true,
+		Extensions:       []string{},
+		fallthroughBool:  true,
+		indexSlice:       []string{"index.html"},
+		lastModifiedBool: true,
+		redirectBool:     true,
+		Param:            "path",
+	}
+
+	// merge configs
+	if len(staticConfig) > 0 {
+		userConfig := staticConfig[0]
+
+		if b, ok := userConfig.AcceptRanges.(bool); ok {
+			config.acceptRangesBool = b
+		}
+		if b, ok := userConfig.CacheControl.(bool); ok {
+			config.cacheControlBool = b
+		}
+		if userConfig.Dotfiles != "" {
+			config.Dotfiles = userConfig.Dotfiles
+		}
+		if b, ok := userConfig.Etag.(bool); ok {
+			config.etagBool = b
+		}
+		if len(userConfig.Extensions) > 0 {
+			config.Extensions = userConfig.Extensions
+		}
+		if b, ok := userConfig.Fallthrough.(bool); ok {
+			config.fallthroughBool = b
+		}
+		if userConfig.Immutable {
+			config.Immutable = userConfig.Immutable
+		}
+		if i, ok := userConfig.Index.(string); ok && i != "" {
+			config.indexSlice = []string{i}
+		} else if is, ok := userConfig.Index.([]string); ok {
+			config.indexSlice = is
+		} else if b, ok := userConfig.Index.(bool); ok && !b {
+			config.indexSlice = []string{}
+		}
+		if b, ok := userConfig.LastModified.(bool); ok {
+			config.lastModifiedBool = b
+		}
+		if userConfig.MaxAge > 0 {
+			config.MaxAge = userConfig.MaxAge
+		}
+		if b, ok := userConfig.Redirect.(bool); ok {
+			config.redirectBool = b
+		}
+		if userConfig.SetHeaders != nil {
+			config.SetHeaders = userConfig.SetHeaders
+		}
+		if userConfig.Param != "" {
+			config.Param = userConfig.Param
+		}
+	}
+
+	// create static middleware
+	static := func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		if req.Native.Method != http.MethodGet && req.Native.Method != http.MethodHead {
+			if config.fallthroughBool {
+				next.Next = true
+				next.Route = true
+				return
+			}
+
+			// method not allowed
+			res.Status(http.StatusMethodNotAllowed)
+			res.Set("Allow", "GET, HEAD")
+			res.Set("Content-Length", "0")
+			res.End()
+			return
+		}
+
+		err := config.serve(req, res, fsys)
+		if err == nil {
+			return
+		}
+
+		// with fallthrough, client errors let the following callbacks handle the request
+		if config.fallthroughBool && (err == ErrForbidden || err == ErrNotFound) {
+			next.Next = true
+			next.Route = true
+			return
+		}
+
+		next.Err = err
+	}
+
+	return static
+}
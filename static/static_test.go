@@ -0,0 +1,90 @@
+package static_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/expressgotest"
+	"github.com/Eandalf/expressgo/static"
+)
+
+var files = fstest.MapFS{
+	"index.html":      {Data: []byte("home")},
+	"about.html":      {Data: []byte("about")},
+	"docs/index.html": {Data: []byte("docs")},
+	"docs/api.txt":    {Data: []byte("api")},
+	"empty/note.txt":  {Data: []byte("note")},
+	".env":            {Data: []byte("secret")},
+	".well-known/a":   {Data: []byte("well-known")},
+}
+
+// Respond with 404 and the path for requests passed on by the static middleware.
+func notFound(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+	res.Status(404).Send("fallthrough " + req.Params["path"])
+}
+
+func TestServe(t *testing.T) {
+	app := expressgo.CreateServer()
+	app.Get("/public/*path", static.ServeFS(files), notFound)
+	app.Post("/public/*path", static.ServeFS(files), notFound)
+	app.Get("/html/*path", static.ServeFS(files, static.StaticConfig{Extensions: []string{"html"}, Index: false, Redirect: false}), notFound)
+
+	agent := expressgotest.New(&app)
+
+	// index files are served for directories
+	agent.Get("/public/").Expect(t).Status(200).Body("home")
+	agent.Get("/public/docs/").Expect(t).Status(200).Body("docs")
+	agent.Get("/public/docs/api.txt").Expect(t).Status(200).Body("api").Header("Content-Type", "text/plain; charset=utf-8")
+
+	// directories without a trailing slash are redirected, keeping the query
+	agent.Get("/public/docs?v=1").Expect(t).Status(301).Header("Location", "/public/docs/?v=1")
+
+	// missing files, directories without index files, and other methods fall through
+	agent.Get("/public/missing.txt").Expect(t).Status(404).Body("fallthrough missing.txt")
+	agent.Get("/public/empty/").Expect(t).Status(404).Body("fallthrough empty/")
+	agent.Get("/public/about.html/").Expect(t).Status(404)
+	agent.Post("/public/index.html").Expect(t).Status(404).Body("fallthrough index.html")
+
+	// dotfiles are ignored by default
+	agent.Get("/public/.env").Expect(t).Status(404).Body("fallthrough .env")
+	agent.Get("/public/.well-known/a").Expect(t).Status(404)
+
+	// extensions are tried, and index files and redirects could be disabled
+	agent.Get("/html/about").Expect(t).Status(200).Body("about")
+	agent.Get("/html/docs").Expect(t).Status(404)
+	agent.Get("/html/docs/").Expect(t).Status(404)
+}
+
+func TestServeWithoutFallthrough(t *testing.T) {
+	app := expressgo.CreateServer()
+	app.Use("/public/*path", static.ServeFS(files, static.StaticConfig{Fallthrough: false, Dotfiles: static.DotfilesDeny}))
+	app.Use("/allow/*path", static.ServeFS(files, static.StaticConfig{Fallthrough: false, Dotfiles: static.DotfilesAllow}))
+	// a path escaping the root, e.g., read from a mounted handler
+	app.Get("/escape", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		req.Params["path"] = "docs/../../secret"
+		next.Next = true
+	}, static.ServeFS(files, static.StaticConfig{Fallthrough: false}))
+	app.UseGlobalError(func(err error, req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		switch err {
+		case static.ErrForbidden:
+			res.Status(403).Send("forbidden")
+		case static.ErrNotFound:
+			res.Status(404).Send("not found")
+		default:
+			res.Status(500).Send(err.Error())
+		}
+	})
+
+	agent := expressgotest.New(&app)
+
+	agent.Get("/public/index.html").Expect(t).Status(200).Body("home")
+	agent.Get("/public/missing.txt").Expect(t).Status(404).Body("not found")
+	agent.Get("/public/.env").Expect(t).Status(403).Body("forbidden")
+	agent.Get("/allow/.env").Expect(t).Status(200).Body("secret")
+	agent.Post("/public/index.html").Expect(t).Status(405).Header("Allow", "GET, HEAD")
+
+	// paths escaping the root are rejected
+	agent.Get("/escape").Expect(t).Status(403).Body("forbidden")
+	agent.Get("/public/..%5c.env").Expect(t).Status(403).Body("forbidden")
+}
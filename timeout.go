@@ -0,0 +1,173 @@
+package expressgo
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// An http.ResponseWriter guarding a response with a timeout, see res.SetTimeout.
+//
+// Headers are kept apart from the underlying ResponseWriter until the chain writes them, so error handlers run on timeout could respond while a callback of the chain is still running, as http.TimeoutHandler does.
+type timeoutWriter struct {
+	native http.ResponseWriter
+	header http.Header
+	// guards all fields below and writes to native
+	mu sync.Mutex
+	// whether the chain has written the status code and headers to native
+	committed bool
+	// whether the chain has finished, after which error handlers are not run on timeout
+	finished bool
+	// whether error handlers have responded on timeout, after which writes of the chain are dropped
+	timedOut bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// The caller should hold w.mu.
+func (w *timeoutWriter) commit(statusCode int) {
+	header := w.native.Header()
+	clear(header)
+	maps.Copy(header, w.header)
+
+	w.native.WriteHeader(statusCode)
+	w.committed = true
+}
+
+func (w *timeoutWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut || w.committed {
+		return
+	}
+	w.commit(statusCode)
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !w.committed {
+		w.commit(http.StatusOK)
+	}
+	return w.native.Write(p)
+}
+
+// For http.ResponseController to flush without racing with error handlers run on timeout.
+func (w *timeoutWriter) FlushError() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timedOut {
+		return http.ErrHandlerTimeout
+	}
+	return http.NewResponseController(w.native).Flush()
+}
+
+// For http.ResponseController to reach the underlying ResponseWriter.
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.native
+}
+
+// Check if error handlers have responded on timeout.
+func (w *timeoutWriter) isTimedOut() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.timedOut
+}
+
+// Set a timeout of the request, after which error handlers are run with the error, unless the status code and headers have been sent.
+//
+// The context of the request is cancelled with the error as the cause once the timeout passes. Error handlers registered after the current callback respond right away, while the current callback could still be running. Callbacks running for long should watch req.Context().Done() and return early, their writes are dropped and the rest of the chain is not run.
+//
+// Error handlers run on timeout see params and locals set before res.SetTimeout is called. If none of them responds, the status code of the error is sent, 503 if it has none.
+func (res *Response) SetTimeout(timeout time.Duration, err error) {
+	if err == nil {
+		err = context.DeadlineExceeded
+	}
+
+	req := res.req
+	ctx, cancel := context.WithTimeoutCause(req.Context(), timeout, err)
+	req.WithContext(ctx)
+
+	w := &timeoutWriter{native: res.native, header: res.native.Header().Clone()}
+	res.native = w
+	req.routing.timeout = w
+
+	// the state of error handlers is taken now, since the chain goes on in its own goroutine
+	r := req.Native.WithContext(context.WithValue(ctx, localsKey{}, maps.Clone(req.Locals)))
+	state := &routing{
+		route:     req.routing.route,
+		callbacks: req.routing.callbacks,
+		params:    maps.Clone(req.Params),
+		index:     req.routing.index,
+		pos:       req.routing.pos,
+		cancelled: true,
+		err:       err,
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		// cancelled by other causes, e.g., client disconnects
+		if context.Cause(ctx) != err {
+			return
+		}
+
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		if w.committed || w.finished {
+			return
+		}
+		w.timedOut = true
+
+		u := &UserHandler{app: res.app}
+		u.serveTimeout(w.native, r, state)
+	})
+
+	res.OnFinish(func() {
+		stop()
+
+		w.mu.Lock()
+		w.finished = true
+		w.mu.Unlock()
+
+		cancel()
+	})
+}
+
+// Serve the request with error handlers after the callback setting the timeout, see res.SetTimeout.
+func (u *UserHandler) serveTimeout(w http.ResponseWriter, r *http.Request, state *routing) {
+	err := state.err
+	req, res := u.createContext(r, w, state)
+	maps.Copy(req.Params, state.params)
+	u.setQuery(r, req)
+
+	defer res.runOnFinish()
+
+	// go on as the callback setting the timeout returns with the error
+	if u.advance(req, res, &Next{}) {
+		u.runCallbacks(req, res)
+	}
+
+	// without error handlers responding, the status code of the error is sent, e.g., 503 of "503: response.timeout", or 503 if it has none
+	if !res.headersSent && !res.end {
+		status, _, ok := statusOf(err)
+		if !ok {
+			status = http.StatusServiceUnavailable
+		}
+		res.Status(status).Send(http.StatusText(status))
+	}
+	res.finish()
+
+	// the chain is still running, so the response is sent to the client now
+	http.NewResponseController(w).Flush()
+}
@@ -0,0 +1,31 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Eandalf/expressgo"
+)
+
+// Passed to error handlers when a request times out.
+var ErrTimeout = errors.New("503: response.timeout")
+
+// Create the middleware setting a timeout of a request, e.g., app.UseGlobal(timeout.Use(5 * time.Second)).
+//
+// Once the timeout passes, the context is cancelled, and ErrTimeout is passed to error handlers registered after the middleware, which should respond with 503, unless the status code and headers have been sent. See res.SetTimeout.
+//
+// Callbacks running for long should watch req.Context().Done() and return early, their writes after the timeout are dropped.
+func Use(timeout time.Duration) expressgo.Callback {
+	return func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.SetTimeout(timeout, ErrTimeout)
+
+		next.Next = true
+		next.Route = true
+	}
+}
+
+// Check if the request has timed out.
+func TimedOut(req *expressgo.Request) bool {
+	return errors.Is(context.Cause(req.Context()), ErrTimeout)
+}
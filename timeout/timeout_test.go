@@ -0,0 +1,104 @@
+package timeout_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Eandalf/expressgo"
+	"github.com/Eandalf/expressgo/expressgotest"
+	"github.com/Eandalf/expressgo/timeout"
+)
+
+// Respond with 503 to requests timed out.
+func sendTimeout(err error, req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+	if errors.Is(err, timeout.ErrTimeout) && !res.HeadersSent() {
+		res.Status(http.StatusServiceUnavailable).Send(err.Error())
+	}
+}
+
+func TestUse(t *testing.T) {
+	app := expressgo.CreateServer()
+	app.UseGlobal(timeout.Use(20 * time.Millisecond))
+
+	reached := false
+	timedOut := make(chan bool, 1)
+	app.Get("/fast", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Send("fast")
+	})
+	// callbacks watching the context return early, and the rest of the chain is not run
+	app.Get("/watch", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		<-req.Context().Done()
+		timedOut <- timeout.TimedOut(req)
+		next.Next = true
+	}, func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		reached = true
+		res.Send("not reached")
+	})
+	// writes after the timeout are dropped
+	app.Get("/late", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		time.Sleep(50 * time.Millisecond)
+		res.Set("X-Late", "true")
+		res.Send("late")
+	})
+	// responses sent before the timeout are kept
+	app.Get("/stream", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		res.Write([]byte("partial "))
+		res.Flush()
+		time.Sleep(50 * time.Millisecond)
+		res.Write([]byte("rest"))
+	})
+	app.UseGlobalError(sendTimeout)
+
+	agent := expressgotest.New(&app)
+	agent.Get("/fast").Expect(t).Status(200).Body("fast")
+	agent.Get("/watch").Expect(t).Status(503).Body(timeout.ErrTimeout.Error())
+	if !<-timedOut || reached {
+		t.Errorf("expected the request timed out and the chain stopped, reached: %v", reached)
+	}
+	agent.Get("/late").Expect(t).Status(503).Body(timeout.ErrTimeout.Error()).NoHeader("X-Late")
+	agent.Get("/stream").Expect(t).Status(200).Body("partial rest")
+}
+
+func TestUseRespondsRightAway(t *testing.T) {
+	app := expressgo.CreateServer()
+	app.UseGlobal(timeout.Use(20 * time.Millisecond))
+
+	release := make(chan struct{})
+	app.Get("/", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		// the callback does not watch the context
+		<-release
+		res.Send("late")
+	})
+	app.UseGlobalError(sendTimeout)
+
+	server := httptest.NewServer(&app)
+	defer server.Close()
+	// the response is sent while the callback is still running
+	defer close(release)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if body, _ := io.ReadAll(resp.Body); resp.StatusCode != http.StatusServiceUnavailable || string(body) != timeout.ErrTimeout.Error() {
+		t.Errorf("expected 503 %q, got %d %q", timeout.ErrTimeout.Error(), resp.StatusCode, body)
+	}
+}
+
+func TestUseWithoutErrorHandlers(t *testing.T) {
+	app := expressgo.CreateServer()
+	app.UseGlobal(timeout.Use(20 * time.Millisecond))
+	app.Get("/", func(req *expressgo.Request, res *expressgo.Response, next *expressgo.Next) {
+		time.Sleep(60 * time.Millisecond)
+		res.Send("late")
+	})
+
+	// 503 is sent by default
+	expressgotest.New(&app).Get("/").Expect(t).Status(503).Body(http.StatusText(http.StatusServiceUnavailable))
+}
@@ -0,0 +1,328 @@
+package expressgo
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Named subnets for "trust proxy".
+var trustPresets = map[string][]string{
+	"loopback":    {"127.0.0.1/8", "::1/128"},
+	"linklocal":   {"169.254.0.0/16", "fe80::/10"},
+	"uniquelocal": {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+}
+
+// Decide if the address at hop i is a trusted proxy, where hop 0 is the socket address and hop i is the i-th address from the right in X-Forwarded-For.
+type trustFunc func(addr string, i int) bool
+
+func trustNone(addr string, i int) bool {
+	return false
+}
+
+// Compile the value of "trust proxy", which could be a bool, a hop count, a string of comma-separated addresses, subnets, or presets, a list of them, or a func(string, int) bool.
+//
+// Return an error if the value is malformed.
+func compileTrust(value any) (trustFunc, error) {
+	switch v := value.(type) {
+	case nil:
+		return trustNone, nil
+	case bool:
+		if !v {
+			return trustNone, nil
+		}
+		return func(addr string, i int) bool { return true }, nil
+	case int:
+		// trust the nearest v hops
+		return func(addr string, i int) bool { return i < v }, nil
+	case string:
+		return compileTrustList(strings.Split(v, ","))
+	case []string:
+		return compileTrustList(v)
+	case func(string, int) bool:
+		return v, nil
+	}
+
+	return nil, errors.New("trust proxy should be a bool, an int, a string, a []string, or a func(string, int) bool")
+}
+
+// Compile a list of addresses, subnets, and presets to a trustFunc.
+func compileTrustList(values []string) (trustFunc, error) {
+	prefixes := []netip.Prefix{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		subnets, ok := trustPresets[value]
+		if !ok {
+			subnets = []string{value}
+		}
+
+		for _, subnet := range subnets {
+			prefix, err := parseSubnet(subnet)
+			if err != nil {
+				return nil, errors.New("trust proxy contains an invalid address or subnet, " + subnet + " is found")
+			}
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return func(addr string, i int) bool {
+		ip, err := netip.ParseAddr(addr)
+		if err != nil {
+			return false
+		}
+		// IPv4-mapped IPv6 addresses match IPv4 subnets
+		ip = ip.Unmap()
+
+		for _, prefix := range prefixes {
+			if prefix.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// Parse an address or a subnet in CIDR notation, where an address is a subnet of itself.
+func parseSubnet(subnet string) (netip.Prefix, error) {
+	if strings.Contains(subnet, "/") {
+		prefix, err := netip.ParsePrefix(subnet)
+		if err != nil {
+			return prefix, err
+		}
+		if prefix.Addr().Is4In6() {
+			if prefix.Bits() < 96 {
+				return prefix, errors.New("invalid IPv4-mapped subnet")
+			}
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		return prefix.Masked(), nil
+	}
+
+	ip, err := netip.ParseAddr(subnet)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	ip = ip.Unmap()
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// Get the trust func and the subdomain offset set on the app.
+func (req *Request) trustConfig() (trustFunc, int) {
+	req.app.mu.RLock()
+	defer req.app.mu.RUnlock()
+
+	trust := req.app.config.trustProxy
+	if trust == nil {
+		trust = trustNone
+	}
+	return trust, req.app.config.subdomainOffset
+}
+
+// Get the address of the socket, without the port.
+func (req *Request) socketAddr() string {
+	host, _, err := net.SplitHostPort(req.Native.RemoteAddr)
+	if err != nil {
+		return req.Native.RemoteAddr
+	}
+	return host
+}
+
+// Parse the Forwarded header (RFC 7239) to a list of elements, each of which maps parameters to values, e.g., for, proto, and host.
+func parseForwarded(header string) []map[string]string {
+	elements := []map[string]string{}
+	element := map[string]string{}
+	pair := ""
+	isQuoted := false
+
+	addPair := func() {
+		k, v, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if found {
+			element[strings.ToLower(strings.TrimSpace(k))] = strings.Trim(strings.TrimSpace(v), `"`)
+		}
+		pair = ""
+	}
+
+	for _, char := range header {
+		switch {
+		case char == '"':
+			isQuoted = !isQuoted
+			pair += string(char)
+		case char == ';' && !isQuoted:
+			addPair()
+		case char == ',' && !isQuoted:
+			addPair()
+			elements = append(elements, element)
+			element = map[string]string{}
+		default:
+			pair += string(char)
+		}
+	}
+	addPair()
+	if len(element) > 0 {
+		elements = append(elements, element)
+	}
+
+	return elements
+}
+
+// Remove the port and brackets from a node of Forwarded, e.g., "[2001:db8::1]:4711" -> "2001:db8::1" and "192.0.2.43:47011" -> "192.0.2.43".
+func forwardedNode(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+	}
+	if strings.Count(node, ":") == 1 {
+		host, _, _ := strings.Cut(node, ":")
+		return host
+	}
+	return node
+}
+
+// Get the addresses the request is forwarded for, from the client to the nearest proxy.
+//
+// X-Forwarded-For is used, or Forwarded if X-Forwarded-For is not sent.
+func (req *Request) forwardedFor() []string {
+	addrs := []string{}
+
+	if header := strings.Join(req.Native.Header.Values("X-Forwarded-For"), ","); header != "" {
+		for _, addr := range strings.Split(header, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+		return addrs
+	}
+
+	for _, element := range parseForwarded(strings.Join(req.Native.Header.Values("Forwarded"), ",")) {
+		if node, ok := element["for"]; ok {
+			addrs = append(addrs, forwardedNode(node))
+		}
+	}
+	return addrs
+}
+
+// Get the value of the first X-Forwarded-* header, or the parameter of the first element of Forwarded if X-Forwarded-* is not sent.
+func (req *Request) forwarded(header string, param string) string {
+	if value := req.Get(header); value != "" {
+		value, _, _ = strings.Cut(value, ",")
+		return strings.TrimSpace(value)
+	}
+
+	if elements := parseForwarded(req.Get("Forwarded")); len(elements) > 0 {
+		return elements[0][param]
+	}
+	return ""
+}
+
+// Get the addresses from the socket to the furthest trusted proxy, including the first untrusted address.
+func (req *Request) trustedAddrs(trust trustFunc) []string {
+	addrs := []string{req.socketAddr()}
+	forwarded := req.forwardedFor()
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addrs = append(addrs, forwarded[i])
+	}
+
+	for i := 0; i < len(addrs)-1; i++ {
+		if !trust(addrs[i], i) {
+			return addrs[:i+1]
+		}
+	}
+	return addrs
+}
+
+// Get the address of the client, which is the furthest address trusted by "trust proxy" in X-Forwarded-For or Forwarded.
+//
+// Without "trust proxy", it is the address of the socket.
+func (req *Request) Ip() string {
+	trust, _ := req.trustConfig()
+	addrs := req.trustedAddrs(trust)
+	return addrs[len(addrs)-1]
+}
+
+// Get the addresses in X-Forwarded-For or Forwarded trusted by "trust proxy", from the client to the furthest trusted proxy.
+//
+// Without "trust proxy", it is empty.
+func (req *Request) Ips() []string {
+	trust, _ := req.trustConfig()
+	addrs := req.trustedAddrs(trust)
+
+	ips := []string{}
+	for i := len(addrs) - 1; i > 0; i-- {
+		ips = append(ips, addrs[i])
+	}
+	return ips
+}
+
+// Get the protocol of the request, "http" or "https".
+//
+// If the socket is trusted by "trust proxy", X-Forwarded-Proto or the proto of Forwarded is used.
+func (req *Request) Protocol() string {
+	protocol := "http"
+	if req.Native.TLS != nil {
+		protocol = "https"
+	}
+
+	trust, _ := req.trustConfig()
+	if !trust(req.socketAddr(), 0) {
+		return protocol
+	}
+
+	if proto := req.forwarded("X-Forwarded-Proto", "proto"); proto != "" {
+		return strings.ToLower(proto)
+	}
+	return protocol
+}
+
+// Check if the protocol of the request is "https".
+func (req *Request) Secure() bool {
+	return req.Protocol() == "https"
+}
+
+// Get the host name of the request from the Host header, without the port.
+//
+// If the socket is trusted by "trust proxy", X-Forwarded-Host or the host of Forwarded is used.
+func (req *Request) Hostname() string {
+	host := req.Native.Host
+
+	trust, _ := req.trustConfig()
+	if trust(req.socketAddr(), 0) {
+		if forwardedHost := req.forwarded("X-Forwarded-Host", "host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	// IPv6 literals are enclosed in brackets, e.g., [::1]:3000
+	offset := 0
+	if strings.HasPrefix(host, "[") {
+		offset = strings.Index(host, "]") + 1
+	}
+	if pos := strings.Index(host[offset:], ":"); pos >= 0 {
+		return host[:offset+pos]
+	}
+	return host
+}
+
+// Get the subdomains of the host name, from the nearest to the top-level domain, with the last "subdomain offset" parts removed, which defaults to 2.
+//
+// e.g., tobi.ferrets.example.com -> ["ferrets", "tobi"]
+func (req *Request) Subdomains() []string {
+	_, offset := req.trustConfig()
+	hostname := req.Hostname()
+
+	if hostname == "" || net.ParseIP(strings.Trim(hostname, "[]")) != nil {
+		return []string{}
+	}
+
+	parts := strings.Split(hostname, ".")
+	subdomains := []string{}
+	for i := len(parts) - 1 - offset; i >= 0; i-- {
+		subdomains = append(subdomains, parts[i])
+	}
+	return subdomains
+}
@@ -0,0 +1,85 @@
+package expressgo
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrustProxy(t *testing.T) {
+	tests := []struct {
+		name       string
+		trust      any
+		remoteAddr string
+		tls        bool
+		headers    map[string]string
+		// ip, ips, protocol, and hostname separated by spaces
+		expected string
+	}{
+		{"untrusted", nil, "10.0.0.1:1234", false, map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"}, "10.0.0.1 - http example.com"},
+		{"untrusted tls", nil, "10.0.0.1:1234", true, map[string]string{"X-Forwarded-Proto": "http"}, "10.0.0.1 - https example.com"},
+		{"trust all", true, "10.0.0.1:1234", false, map[string]string{"X-Forwarded-For": "1.1.1.1, 10.0.0.2", "X-Forwarded-Proto": "HTTPS, http", "X-Forwarded-Host": "api.example.com:8080"}, "1.1.1.1 1.1.1.1,10.0.0.2 https api.example.com"},
+		// the nearest hops are trusted, so addresses spoofed by the client beyond them are ignored
+		{"hops", 1, "10.0.0.1:1234", false, map[string]string{"X-Forwarded-For": "6.6.6.6, 1.1.1.1"}, "1.1.1.1 1.1.1.1 http example.com"},
+		{"two hops", 2, "10.0.0.1:1234", false, map[string]string{"X-Forwarded-For": "6.6.6.6, 1.1.1.1, 10.0.0.2"}, "1.1.1.1 1.1.1.1,10.0.0.2 http example.com"},
+		{"hops beyond header", 5, "10.0.0.1:1234", false, map[string]string{"X-Forwarded-For": "1.1.1.1"}, "1.1.1.1 1.1.1.1 http example.com"},
+		// subnets stop at the first untrusted address from the socket
+		{"subnets", "loopback, 10.0.0.0/8", "127.0.0.1:1234", false, map[string]string{"X-Forwarded-For": "6.6.6.6, 1.1.1.1, 10.1.2.3"}, "1.1.1.1 1.1.1.1,10.1.2.3 http example.com"},
+		{"subnet list", []string{"uniquelocal"}, "192.168.1.1:1234", false, map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Forwarded-Proto": "https"}, "1.1.1.1 1.1.1.1 https example.com"},
+		{"untrusted socket", "loopback", "10.0.0.1:1234", false, map[string]string{"X-Forwarded-For": "1.1.1.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"}, "10.0.0.1 - http example.com"},
+		{"ipv4-mapped socket", "10.0.0.0/8", "[::ffff:10.0.0.1]:1234", false, map[string]string{"X-Forwarded-For": "1.1.1.1"}, "1.1.1.1 1.1.1.1 http example.com"},
+		{"ipv6", "::1", "[::1]:1234", false, map[string]string{"X-Forwarded-For": "2001:db8::1", "X-Forwarded-Host": "[2001:db8::2]:8080"}, "2001:db8::1 2001:db8::1 http [2001:db8::2]"},
+		{"predicate", func(addr string, i int) bool { return addr == "10.0.0.1" }, "10.0.0.1:1234", false, map[string]string{"X-Forwarded-For": "1.1.1.1, 10.0.0.1"}, "1.1.1.1 1.1.1.1,10.0.0.1 http example.com"},
+		// the socket address is used as it is without a port
+		{"missing port", "10.0.0.1", "10.0.0.1", false, map[string]string{"X-Forwarded-For": "1.1.1.1"}, "1.1.1.1 1.1.1.1 http example.com"},
+		{"missing port untrusted", nil, "10.0.0.1", false, nil, "10.0.0.1 - http example.com"},
+		// Forwarded is used if X-Forwarded-* is not sent
+		{"forwarded", true, "10.0.0.1:1234", false, map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https;host=forwarded.com, for=10.0.0.2`}, "2001:db8::1 2001:db8::1,10.0.0.2 https forwarded.com"},
+	}
+
+	for _, test := range tests {
+		app := CreateServer()
+		if test.trust != nil {
+			app.Set("trust proxy", test.trust)
+		}
+		app.Get("/", func(req *Request, res *Response, next *Next) {
+			ips := strings.Join(req.Ips(), ",")
+			if ips == "" {
+				ips = "-"
+			}
+			res.Send(strings.Join([]string{req.Ip(), ips, req.Protocol(), req.Hostname()}, " "))
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.tls {
+			r.TLS = &tls.ConnectionState{}
+		}
+		for k, v := range test.headers {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		app.handler.ServeHTTP(w, r)
+
+		if body, _ := io.ReadAll(w.Result().Body); string(body) != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, body)
+		}
+	}
+}
+
+func TestTrustProxyErrors(t *testing.T) {
+	app := CreateServer()
+	app.Set("trust proxy", "loopback")
+
+	// malformed values are refused, and the previous value is kept
+	for _, value := range []any{"10.0.0.0/33", []string{"loopback", "not an address"}, 1.5} {
+		app.Set("trust proxy", value)
+
+		if app.GetData("trust proxy") != "loopback" || !app.config.trustProxy("127.0.0.1", 0) {
+			t.Errorf("%v: expected the setting refused", value)
+		}
+	}
+}
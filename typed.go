@@ -0,0 +1,332 @@
+package expressgo
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The limit of bodies read by typed handlers if they are not parsed by body parsers.
+const typedBodyLimit = 100 * 1024
+
+var errTypedBodyTooLarge = errors.New("413: entity.too.large")
+
+// Returned when a request could not be bound to the input of a typed handler, which is responded with 400.
+type BindError struct {
+	// messages by field names, "" for the body, e.g., {"page": "should be an integer"}
+	Fields map[string]string
+}
+
+func (e *BindError) Error() string {
+	messages := []string{}
+	for _, name := range slices.Sorted(maps.Keys(e.Fields)) {
+		messages = append(messages, strings.TrimSpace(name+" "+e.Fields[name]))
+	}
+	return "400: " + strings.Join(messages, ", ")
+}
+
+func (e *BindError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Create a callback from a typed handler, e.g., expressgo.Handle(func(ctx context.Context, in CreateUser) (User, error) {...}).
+//
+// The input is bound from the request with struct tags:
+//
+// - json: the JSON body, or req.Body parsed by body parsers
+//
+// - form: the urlencoded body, falling back to the json tag
+//
+// - query: the query string
+//
+// - param: path params
+//
+// Later sources override earlier ones in the order above. Bind failures are responded with 400 and messages by fields.
+//
+// The output is sent as JSON. Errors with a status code, either implementing StatusCode() int or in the form of "404: not found", are responded with the status code and the message as JSON. Other errors are passed to error handlers.
+//
+// The context is req.Context(), so request locals could be read with LocalsFrom.
+func Handle[In any, Out any](handler func(ctx context.Context, in In) (Out, error)) Callback {
+	return func(req *Request, res *Response, next *Next) {
+		var in In
+		if err := bind(req, &in); err != nil {
+			var bindErr *BindError
+			if errors.As(err, &bindErr) {
+				next.Err = res.Status(http.StatusBadRequest).Json(map[string]any{
+					"error":  http.StatusText(http.StatusBadRequest),
+					"fields": bindErr.Fields,
+				})
+				return
+			}
+			if status, message, ok := statusOf(err); ok {
+				next.Err = res.Status(status).Json(map[string]string{"error": message})
+				return
+			}
+			next.Err = err
+			return
+		}
+
+		out, err := handler(req.Context(), in)
+		if err != nil {
+			if status, message, ok := statusOf(err); ok {
+				next.Err = res.Status(status).Json(map[string]string{"error": message})
+				return
+			}
+			next.Err = err
+			return
+		}
+
+		next.Err = res.Json(out)
+	}
+}
+
+// Get the status code and the message of an error, either implementing StatusCode() int or in the form of "404: not found".
+//
+// Return false if the error has no status code.
+func statusOf(err error) (int, string, bool) {
+	status := 0
+	message := err.Error()
+	if len(message) > 4 && message[3] == ':' {
+		if s, convErr := strconv.Atoi(message[:3]); convErr == nil && s >= 400 && s < 600 {
+			status = s
+			message = strings.TrimSpace(message[4:])
+		}
+	}
+
+	var coder interface{ StatusCode() int }
+	if errors.As(err, &coder) {
+		status = coder.StatusCode()
+	}
+
+	if status == 0 {
+		return 0, "", false
+	}
+	return status, message, true
+}
+
+// Bind the request to the target, which is a pointer.
+//
+// Return a *BindError if values could not be converted to the fields, or other errors if the body could not be read.
+func bind(req *Request, target any) error {
+	v := reflect.ValueOf(target).Elem()
+	// allocate pointers, e.g., In is *CreateUser
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	fields := map[string]string{}
+
+	if err := bindBody(req, v, fields); err != nil {
+		return err
+	}
+
+	if v.Kind() == reflect.Struct {
+		bindValues(v, "query", req.Native.URL.Query(), fields)
+
+		params := url.Values{}
+		for name, value := range req.Params {
+			params.Set(name, value)
+		}
+		bindValues(v, "param", params, fields)
+	}
+
+	if len(fields) > 0 {
+		return &BindError{Fields: fields}
+	}
+	return nil
+}
+
+// Bind the body to the value, either req.Body parsed by body parsers or the JSON or urlencoded body read from the request.
+func bindBody(req *Request, v reflect.Value, fields map[string]string) error {
+	switch body := req.Body.(type) {
+	case nil:
+		if req.Is("json", "urlencoded") == "" {
+			return nil
+		}
+
+		data, err := io.ReadAll(io.LimitReader(req.Native.Body, typedBodyLimit+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > typedBodyLimit {
+			return errTypedBodyTooLarge
+		}
+
+		if req.Is("json") != "" {
+			bindJson(data, v, fields)
+			return nil
+		}
+
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			fields[""] = "body is not a valid urlencoded form"
+			return nil
+		}
+		bindValues(v, "form", values, fields)
+	case BodyFormUrlEncoded:
+		values := url.Values{}
+		for name, value := range body {
+			values.Set(name, value)
+		}
+		bindValues(v, "form", values, fields)
+	case string, []byte:
+		// text and raw bodies could not be bound
+	default:
+		// JSON bodies parsed with any receivers are encoded again for the input
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		bindJson(data, v, fields)
+	}
+
+	return nil
+}
+
+// Decode the JSON data to the value, and record the field with a mismatched type.
+func bindJson(data []byte, v reflect.Value, fields map[string]string) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return
+	}
+
+	err := json.Unmarshal(data, v.Addr().Interface())
+	if err == nil {
+		return
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		fields[typeErr.Field] = "should be " + describeType(typeErr.Type)
+		return
+	}
+	fields[""] = "body is not valid JSON"
+}
+
+// Set values to the fields of the struct with the tag, e.g., `query:"page"`, including fields of embedded structs.
+func bindValues(v reflect.Value, tag string, values url.Values, fields map[string]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		// form fields fall back to json names
+		if name == "" && tag == "form" {
+			name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+		}
+
+		// exported fields of embedded structs could be set even if the structs are unexported
+		if name == "" && field.Anonymous && field.Type.Kind() == reflect.Struct {
+			bindValues(v.Field(i), tag, values, fields)
+			continue
+		}
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		if err := setValue(v.Field(i), vs); err != nil {
+			fields[name] = err.Error()
+		}
+	}
+}
+
+// Describe a type in messages of bind failures.
+func describeType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
+
+// Convert strings to the value, where slices take all strings and other types take the first one.
+func setValue(v reflect.Value, vs []string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), vs)
+	}
+
+	invalid := errors.New("should be " + describeType(v.Type()))
+
+	// e.g., time.Time
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(vs[0])); err != nil {
+			return invalid
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		s := reflect.MakeSlice(v.Type(), len(vs), len(vs))
+		for i := range vs {
+			if err := setValue(s.Index(i), vs[i:i+1]); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.String:
+		v.SetString(vs[0])
+	case reflect.Bool:
+		b, err := strconv.ParseBool(vs[0])
+		if err != nil {
+			return invalid
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(vs[0], 10, v.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(vs[0], 10, v.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(vs[0], v.Type().Bits())
+		if err != nil {
+			return invalid
+		}
+		v.SetFloat(n)
+	default:
+		return invalid
+	}
+
+	return nil
+}
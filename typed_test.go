@@ -0,0 +1,93 @@
+package expressgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type pagination struct {
+	Page int `query:"page"`
+}
+
+type updateBook struct {
+	pagination
+	Id    int      `param:"id"`
+	Title string   `json:"title"`
+	Year  int      `json:"year"`
+	Tags  []string `query:"tag"`
+}
+
+type book struct {
+	Id    int      `json:"id"`
+	Title string   `json:"title"`
+	Year  int      `json:"year"`
+	Tags  []string `json:"tags"`
+	Page  int      `json:"page"`
+}
+
+func TestHandle(t *testing.T) {
+	app := CreateServer()
+
+	app.Put("/books/:id", Handle(func(ctx context.Context, in updateBook) (book, error) {
+		if in.Id == 0 {
+			return book{}, errors.New("404: book not found")
+		}
+		if in.Id == 500 {
+			return book{}, errors.New("storage is down")
+		}
+		return book{Id: in.Id, Title: in.Title, Year: in.Year, Tags: in.Tags, Page: in.Page}, nil
+	}))
+	app.UseGlobalError(func(err error, req *Request, res *Response, next *Next) {
+		res.Status(http.StatusInternalServerError).Send("handled: " + err.Error())
+	})
+
+	tests := []struct {
+		target      string
+		contentType string
+		body        string
+		status      int
+		response    string
+	}{
+		{
+			"/books/1?page=2&tag=a&tag=b", "application/json", `{"title":"Go","year":2024}`,
+			200, `{"id":1,"title":"Go","year":2024,"tags":["a","b"],"page":2}`,
+		},
+		{
+			"/books/1", "application/x-www-form-urlencoded", "title=Go&year=2024",
+			200, `{"id":1,"title":"Go","year":2024,"tags":null,"page":0}`,
+		},
+		{
+			"/books/abc?page=x", "application/json", `{"year":"2024"}`,
+			400, `{"error":"Bad Request","fields":{"id":"should be an integer","page":"should be an integer","year":"should be an integer"}}`,
+		},
+		{
+			"/books/1", "application/json", `{"title":`,
+			400, `{"error":"Bad Request","fields":{"":"body is not valid JSON"}}`,
+		},
+		{
+			"/books/0", "application/json", `{}`,
+			404, `{"error":"book not found"}`,
+		},
+		{
+			"/books/500", "application/json", `{}`,
+			500, "handled: storage is down",
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPut, test.target, strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.contentType)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		body, _ := io.ReadAll(w.Result().Body)
+		if w.Code != test.status || string(body) != test.response {
+			t.Errorf("%s: expected %d %s, got %d %s", test.target, test.status, test.response, w.Code, body)
+		}
+	}
+}
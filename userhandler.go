@@ -1,9 +1,14 @@
 package expressgo
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"maps"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 type Next struct {
@@ -16,42 +21,131 @@ type Callback func(req *Request, res *Response, next *Next)
 
 type ErrorCallback func(err error, req *Request, res *Response, next *Next)
 
+type ParamCallback func(req *Request, res *Response, next *Next, value string)
+
+// The handler registered to ServeMux for all routes.
+//
+// It holds no per-request data, all states of a request are kept in routing.
 type UserHandler struct {
-	app       *App
-	callbacks []Callback
-	route     string
+	app *App
+}
+
+// Per-request routing state.
+type routing struct {
+	// the matched route, r.Pattern
+	route string
+	// lists of callbacks associated with the route when the request is received
+	callbacks [][]Callback
+	// values of params of the lists of callbacks, which match their constraints
+	params map[string]string
+	// index of the current list of callbacks
+	index int
+	// position of the current callback in the current list
+	pos int
+	// whether the rest of the chain has been run inside a callback, see UserHandler.resume
+	resumed bool
+	// whether the cause of the cancellation of the context has been passed to error handlers
+	cancelled bool
+	// the response guarded by res.SetTimeout, whose error handlers might have responded instead of the chain
+	timeout *timeoutWriter
+	// the error to be handled by error-handling callbacks
+	err error
+	// the next of the current callback, see res.Format
+	next *Next
+}
+
+// Get the URL sent by the client.
+//
+// r.URL.Path could be rewritten by Handler.ServeHTTP, r.RequestURI keeps the one sent by the client.
+func getOriginalUrl(r *http.Request) string {
+	if r.RequestURI == "" {
+		return r.URL.RequestURI()
+	}
+
+	return r.RequestURI
 }
 
-func (u *UserHandler) createContext(r *http.Request, w http.ResponseWriter) (*Request, *Response) {
+// Get the path sent by the client, without the query string.
+func getOriginalPath(r *http.Request) string {
+	return strings.SplitN(getOriginalUrl(r), "?", 2)[0]
+}
+
+func (u *UserHandler) createContext(r *http.Request, w http.ResponseWriter, state *routing) (*Request, *Response) {
+	originalUrl := getOriginalUrl(r)
+
+	// locals are kept in the context, so they are shared with libraries and apps the request is passed to, e.g., with app.UseHandler
+	locals := LocalsFrom(r.Context())
+	if locals == nil {
+		locals = Locals{}
+		r = r.WithContext(context.WithValue(r.Context(), localsKey{}, locals))
+	}
+
 	req := &Request{
-		Native: r,
-		Params: map[string]string{},
-		Query:  map[string]string{},
+		Native:       r,
+		Params:       map[string]string{},
+		Query:        map[string]string{},
+		Locals:       locals,
+		OriginalUrl:  originalUrl,
+		originalPath: strings.SplitN(originalUrl, "?", 2)[0],
+		routing:      state,
+		app:          u.app,
 	}
 	res := &Response{
-		native:     w,
-		end:        false,
-		statusCode: 0,
-		body:       "",
+		Locals:      locals,
+		native:      w,
+		app:         u.app,
+		req:         req,
+		end:         false,
+		statusCode:  0,
+		headersSent: false,
 	}
 	return req, res
 }
 
-func (u *UserHandler) setParams(r *http.Request, req *Request) {
-	for _, paramsInZone := range u.app.params[r.Pattern] {
-		param := ""
-		for _, p := range paramsInZone {
-			param += p
+// Get the value of a wildcard from the original path.
+//
+// The path matched by ServeMux might be rewritten to lower case with a trailing slash, which should not be seen in the value.
+func getWildcard(pattern string, pathValue func(name string) string, originalPath string, name string) string {
+	path, err := url.PathUnescape(originalPath)
+	pos := strings.Index(pattern, "{"+name+"...}")
+	if err != nil || pos < 0 {
+		return pathValue(name)
+	}
+
+	// skip the segments before the wildcard
+	for i := strings.Count(pattern[:pos], "/"); i > 0; i-- {
+		next := strings.IndexByte(path, '/')
+		if next < 0 {
+			return ""
+		}
+		path = path[next+1:]
+	}
+
+	return path
+}
+
+// Get values of params divided by param zones, where the param zone k is matched by {pk} of the pattern, see Handler.nameByPosition.
+//
+// pathValue gets values of wildcards of the pattern, e.g., r.PathValue.
+func getParams(pattern string, pathValue func(name string) string, originalPath string, zones [][]string) map[string]string {
+	params := map[string]string{}
+
+	for k, paramsInZone := range zones {
+		name := "p" + strconv.Itoa(k)
+
+		if len(paramsInZone) == 2 && paramsInZone[1] == "0S" {
+			params[paramsInZone[0]] = getWildcard(pattern, pathValue, originalPath, name)
+			continue
 		}
 
-		values := r.PathValue(param)
+		values := pathValue(name)
 
 		value := ""
 		paramIndex := 0
 		for _, char := range values {
 			if char == '-' {
 				if paramIndex+1 < len(paramsInZone) && paramsInZone[paramIndex+1] == "0H" {
-					req.Params[paramsInZone[paramIndex]] = value
+					params[paramsInZone[paramIndex]] = value
 
 					// for next param
 					value = ""
@@ -61,7 +155,7 @@ func (u *UserHandler) setParams(r *http.Request, req *Request) {
 				}
 			} else if char == '.' {
 				if paramIndex+1 < len(paramsInZone) && paramsInZone[paramIndex+1] == "0D" {
-					req.Params[paramsInZone[paramIndex]] = value
+					params[paramsInZone[paramIndex]] = value
 
 					// for next param
 					value = ""
@@ -75,15 +169,17 @@ func (u *UserHandler) setParams(r *http.Request, req *Request) {
 		}
 
 		if value != "" && paramIndex < len(paramsInZone) {
-			req.Params[paramsInZone[paramIndex]] = value
+			params[paramsInZone[paramIndex]] = value
 			paramIndex += 2
 		}
 
 		// if any remaining param is not assigned with a value, assign "" to it
 		for ; paramIndex < len(paramsInZone); paramIndex += 2 {
-			req.Params[paramsInZone[paramIndex]] = ""
+			params[paramsInZone[paramIndex]] = ""
 		}
 	}
+
+	return params
 }
 
 // Set req.Query[string]string from r.URL.Query().Get(string).
@@ -117,95 +213,231 @@ func (u *UserHandler) runCallback(
 	c(req, res, next)
 }
 
-// Go through callbacks
+// Decide where the chain goes after the current callback returns with next.
+//
+// Return true if the chain should go on with the callback at the new position.
+func (u *UserHandler) advance(
+	req *Request,
+	res *Response,
+	next *Next,
+) bool {
+	state := req.routing
+	isLast := state.pos == len(state.callbacks[state.index])-1
+
+	// transfer the error from next to req
+	if next.Err != nil {
+		state.err = next.Err
+		next.Err = nil
+	}
+	// if the error is not consumed, activate next.Next or next.Route to pass the error to error handlers down the callback lists
+	if state.err != nil {
+		if isLast {
+			next.Route = true
+		} else {
+			next.Next = true
+		}
+	}
+
+	// do not proceed if the respond is meant to be sent, even with next.Next ot next.Route is set
+	if res.end {
+		return false
+	}
+
+	// next.Next takes precedence over next.Route
+	// next.Next is meaningless with the last callback in the current callback list
+	// this check is implemented to have next.Route in effect with the last callback
+	if next.Next && !isLast {
+		state.pos += 1
+		return true
+	}
+
+	// check next route
+	if next.Route {
+		state.index += 1
+		state.pos = 0
+		return true
+	}
+
+	return false
+}
+
+// Check the context of the request before running a callback.
+//
+// Return false if the chain should stop, which is the case when the context is cancelled without a cause, e.g., by client disconnects, or error handlers have responded on timeout, see res.SetTimeout.
+//
+// The cause set on cancellation, e.g., by context.WithTimeoutCause, is passed to error handlers once, as is the error of a context past its deadline without a cause.
+func (u *UserHandler) checkContext(req *Request) bool {
+	if req.routing.timeout != nil && req.routing.timeout.isTimedOut() {
+		return false
+	}
+
+	ctx := req.Context()
+	if ctx.Err() == nil {
+		return true
+	}
+
+	cause := context.Cause(ctx)
+	if cause == context.Canceled {
+		return false
+	}
+
+	if !req.routing.cancelled {
+		req.routing.cancelled = true
+		req.routing.err = cause
+	}
+	return true
+}
+
+// Go through lists of callbacks associated with the route, starting from the current position.
 func (u *UserHandler) runCallbacks(
-	callbacks []Callback,
-	currentCallbackSetIndex int,
 	req *Request,
 	res *Response,
-	w http.ResponseWriter,
 ) {
-	for pos, c := range callbacks {
+	state := req.routing
+	for state.index < len(state.callbacks) {
+		callbacks := state.callbacks[state.index]
+		// an empty list of callbacks ends the chain
+		if len(callbacks) == 0 {
+			return
+		}
+
+		// stop the chain if the request is cancelled
+		if !u.checkContext(req) {
+			return
+		}
+
 		// create a new next for each callback
 		next := &Next{Next: false, Route: false, Err: nil}
+		state.next = next
 
-		u.runCallback(c, req, res, next)
+		u.runCallback(callbacks[state.pos], req, res, next)
 
-		// perform the write, res -> ResponseWriter
-		if res.statusCode != 0 {
-			w.WriteHeader(res.statusCode)
-		}
-		if res.body != "" {
-			io.WriteString(w, res.body)
+		// a hook registered by res.OnHeaders has failed the response, error handlers respond instead, or 500 is sent without them
+		if res.headersErr != nil {
+			next.Err = res.headersErr
+			res.headersErr = nil
+			res.end = false
+			res.statusCode = http.StatusInternalServerError
+			// the length of the failed body does not apply
+			res.native.Header().Del("Content-Length")
 		}
 
-		// transfer the error from next to req
-		if next.Err != nil {
-			req.err = next.Err
-			next.Err = nil
-		}
-		// if the error is not consumed, activate next.Next or next.Route to pass the error to error handlers down the callback lists
-		if req.err != nil {
-			if pos == (len(callbacks) - 1) {
-				next.Route = true
-			} else {
-				next.Next = true
-			}
+		// the rest of the chain has been run inside the callback
+		if state.resumed {
+			return
 		}
 
-		// do not proceed if the respond is meant to be sent, even with next.Next ot next.Route is set
-		if res.end {
-			break
+		if !u.advance(req, res, next) {
+			return
 		}
+	}
+}
 
-		// next.Next takes precedence over next.Route
-		// next.Next is meaningless with the last callback in the current callback list
-		// this check is implemented to have next.Route in effect with the last callback
-		if next.Next && pos != (len(callbacks)-1) {
+// Run the rest of the chain inside the current callback, as if the callback has returned with next.
+//
+// It is for callbacks which act after the rest of the chain, e.g., net/http middlewares wrapped by Wrap.
+func (u *UserHandler) resume(
+	req *Request,
+	res *Response,
+	next *Next,
+) {
+	if u.advance(req, res, next) {
+		u.runCallbacks(req, res)
+	}
+
+	req.routing.resumed = true
+}
+
+// Take a snapshot of the route matching the request, so registrations while serving would not affect this request.
+//
+// Lists of callbacks with params not matching their constraints are left out, since routes of the same shape share the route, e.g., /posts/:id(\d+) and /posts/:slug([a-z]+).
+//
+// Return the routing state, and whether any list left answers the request.
+func (u *UserHandler) route(r *http.Request) (*routing, bool) {
+	u.app.mu.RLock()
+	callbacks := u.app.callbacks[r.Pattern]
+	lists := u.app.lists[r.Pattern]
+	start := u.app.routeStarts[r.Pattern]
+	u.app.mu.RUnlock()
+
+	state := &routing{
+		route:     r.Pattern,
+		callbacks: [][]Callback{},
+		params:    map[string]string{},
+		index:     0,
+	}
+	originalPath := getOriginalPath(r)
+	isAnswering := false
+	// params of the lists left, and the position of callbacks set by app.Param in them
+	params := [][]string{}
+	paramStart := 0
+
+	for i, info := range lists {
+		values := getParams(r.Pattern, r.PathValue, originalPath, info.params)
+		if !info.matches(values) {
 			continue
 		}
 
-		// check next route
-		if next.Route {
-			// ensure the index is not out of the boundary
-			if currentCallbackSetIndex+1 > len(u.app.callbacks[u.route])-1 {
-				break
+		// a param found in multiple lists takes the value of the first one
+		for name, value := range values {
+			if _, ok := state.params[name]; !ok {
+				state.params[name] = value
 			}
-
-			// get the next list of callbacks associated with the designated route
-			nextCallbacks := u.app.callbacks[u.route][currentCallbackSetIndex+1]
-
-			// run callbacks
-			u.runCallbacks(
-				nextCallbacks,
-				currentCallbackSetIndex+1,
-				req,
-				res,
-				w,
-			)
-			break
 		}
 
-		// check next status
-		if !next.Next {
-			break
+		if i < start {
+			paramStart += 1
 		}
+		state.callbacks = append(state.callbacks, callbacks[i])
+		params = append(params, info.params...)
+		isAnswering = isAnswering || !info.middleware
 	}
+
+	// callbacks set by app.Param are run before callbacks of the route, after global callbacks registered before the route
+	u.app.mu.RLock()
+	paramCallbacks := u.app.getParamCallbacks(params)
+	u.app.mu.RUnlock()
+	if len(paramCallbacks) > 0 {
+		state.callbacks = slices.Concat(state.callbacks[:paramStart], [][]Callback{paramCallbacks}, state.callbacks[paramStart:])
+	}
+
+	return state, isAnswering
 }
 
 func (u *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// save the route first
-	u.route = r.Pattern
+	// a HEAD request matched with a GET route, see Handler.ServeHTTP
+	if app, _ := r.Context().Value(autoHeadKey{}).(*App); app == u.app {
+		r.Method = http.MethodHead
+	}
+
+	state, isAnswering := u.route(r)
 
+	// requests passing through middlewares of a route without routes answering them are served as not found or method not allowed
+	if !isAnswering {
+		u.app.handler.serveFallback(w, r, u.app.handler.allowedMethods(r), state)
+		return
+	}
+
+	u.serve(w, r, state)
+}
+
+// Serve the request with the lists of callbacks in the routing state.
+func (u *UserHandler) serve(w http.ResponseWriter, r *http.Request, state *routing) {
 	// prepare custom objects, including req, res, and next
-	req, res := u.createContext(r, w)
+	req, res := u.createContext(r, w, state)
 
 	// append params
-	u.setParams(r, req)
+	maps.Copy(req.Params, state.params)
 
 	// set the query
 	u.setQuery(r, req)
 
+	// hooks of res.OnFinish are run even if a callback panics, which is not recovered in development environments
+	defer res.runOnFinish()
+
 	// execute the callbacks
-	u.runCallbacks(u.callbacks, 0, req, res, w)
+	u.runCallbacks(req, res)
+
+	// complete the response
+	res.finish()
 }
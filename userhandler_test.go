@@ -0,0 +1,278 @@
+package expressgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Send a request to the app and return the status code and the body.
+func serve(app App, method string, target string) (int, string) {
+	w := httptest.NewRecorder()
+	app.handler.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	body, _ := io.ReadAll(w.Result().Body)
+	return w.Result().StatusCode, string(body)
+}
+
+// Register routes with several lists of callbacks chained by next.Route.
+func createChainedApp(routes int) App {
+	app := CreateServer()
+
+	app.UseGlobal(func(req *Request, res *Response, next *Next) {
+		req.Params["global"] = "global"
+		next.Route = true
+	})
+
+	for i := 0; i < routes; i++ {
+		id := strconv.Itoa(i)
+		path := "/route/" + id + "/:param"
+
+		app.Get(path, func(req *Request, res *Response, next *Next) {
+			req.Params["first"] = id
+			next.Route = true
+		})
+		app.Get(path, func(req *Request, res *Response, next *Next) {
+			res.Send(fmt.Sprintf("%s %s %s %s", id, req.Params["first"], req.Params["param"], req.Params["global"]))
+		})
+	}
+
+	return app
+}
+
+func TestConcurrentRoutes(t *testing.T) {
+	const routes = 8
+	const requests = 200
+
+	app := createChainedApp(routes)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			id := strconv.Itoa(i % routes)
+			param := strconv.Itoa(i)
+			status, body := serve(app, "GET", "/route/"+id+"/"+param)
+
+			expected := fmt.Sprintf("%s %s %s global", id, id, param)
+			if status != 200 || body != expected {
+				t.Errorf("GET /route/%s/%s: expected 200 %q, got %d %q", id, param, expected, status, body)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentErrors(t *testing.T) {
+	const requests = 200
+
+	app := CreateServer()
+	app.Get("/error/:id", func(req *Request, res *Response, next *Next) {
+		next.Err = fmt.Errorf("error %s", req.Params["id"])
+	})
+	app.UseGlobalError(func(err error, req *Request, res *Response, next *Next) {
+		res.Send(err.Error())
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			id := strconv.Itoa(i)
+			_, body := serve(app, "GET", "/error/"+id)
+
+			if body != "error "+id {
+				t.Errorf("GET /error/%s: expected %q, got %q", id, "error "+id, body)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRegisterWhileServing(t *testing.T) {
+	const routes = 8
+	const requests = 200
+
+	app := createChainedApp(routes)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+
+			id := strconv.Itoa(i % routes)
+			status, _ := serve(app, "GET", "/route/"+id+"/"+id)
+			if status != 200 {
+				t.Errorf("GET /route/%s/%s: expected 200, got %d", id, id, status)
+			}
+		}(i)
+		go func(i int) {
+			defer wg.Done()
+
+			// register a new route, add a callback list to an existing route, and write to the data table
+			app.Get("/new/"+strconv.Itoa(i), func(req *Request, res *Response, next *Next) {
+				res.Send("new")
+			})
+			app.Get("/route/"+strconv.Itoa(i%routes)+"/:param", func(req *Request, res *Response, next *Next) {
+				res.Send("appended")
+			})
+			app.Set("key", i)
+			app.GetData("key")
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestGlobalCallbacksNotShared(t *testing.T) {
+	app := CreateServer()
+
+	// globalCallbacks would have spare capacity after appending three lists
+	for i := 0; i < 3; i++ {
+		app.UseGlobal(func(req *Request, res *Response, next *Next) {
+			next.Route = true
+		})
+	}
+
+	app.Get("/a", func(req *Request, res *Response, next *Next) {
+		res.Send("a")
+	})
+	app.Get("/b", func(req *Request, res *Response, next *Next) {
+		res.Send("b")
+	})
+
+	if _, body := serve(app, "GET", "/a"); body != "a" {
+		t.Errorf("GET /a: expected %q, got %q", "a", body)
+	}
+	if _, body := serve(app, "GET", "/b"); body != "b" {
+		t.Errorf("GET /b: expected %q, got %q", "b", body)
+	}
+}
+
+func TestParam(t *testing.T) {
+	app := CreateServer()
+	calls := 0
+
+	app.UseGlobal(func(req *Request, res *Response, next *Next) {
+		req.Params["global"] = "global"
+		next.Route = true
+	})
+	// next.Route does not skip other callbacks of the param
+	app.Param("userId", func(req *Request, res *Response, next *Next, value string) {
+		calls += 1
+		next.Route = true
+	})
+	app.Param("userId", func(req *Request, res *Response, next *Next, value string) {
+		calls += 1
+		if value == "0" {
+			next.Err = errors.New("user not found")
+			return
+		}
+		// global callbacks registered before the route are run first
+		req.Params["user"] = "user " + value + " " + req.Params["global"]
+		next.Next = true
+	})
+
+	app.Get("/users/:userId{/:tab}", func(req *Request, res *Response, next *Next) {
+		next.Route = true
+	})
+	app.Get("/users/:userId{/:tab}", func(req *Request, res *Response, next *Next) {
+		res.Send(req.Params["user"])
+	})
+	// callbacks of a param found in routes of the same shape are run once
+	app.Get("/users/:userId/:section(settings)", func(req *Request, res *Response, next *Next) {
+		res.Send("settings")
+	})
+	app.Get("/about", func(req *Request, res *Response, next *Next) {
+		res.Send("about")
+	})
+	app.UseGlobalError(func(err error, req *Request, res *Response, next *Next) {
+		res.Status(404).Send(err.Error())
+	})
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+		calls  int
+	}{
+		{"/users/42", 200, "user 42 global", 2},
+		{"/users/42/posts", 200, "user 42 global", 2},
+		{"/users/42/settings", 200, "user 42 global", 2},
+		{"/users/0", 404, "user not found", 2},
+		{"/about", 200, "about", 0},
+	}
+
+	for _, test := range tests {
+		calls = 0
+		status, body := serve(app, "GET", test.target)
+		if status != test.status || body != test.body || calls != test.calls {
+			t.Errorf("%s: expected %d %q with %d calls, got %d %q with %d calls", test.target, test.status, test.body, test.calls, status, body, calls)
+		}
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	app := CreateServer()
+	errCause := errors.New("cancelled with a cause")
+	reached := false
+
+	app.Get("/cause", func(req *Request, res *Response, next *Next) {
+		ctx, cancel := context.WithCancelCause(req.Context())
+		req.WithContext(ctx)
+		cancel(errCause)
+		next.Next = true
+	}, func(req *Request, res *Response, next *Next) {
+		reached = true
+		res.Send("not reached")
+	})
+	app.UseError("/cause", func(err error, req *Request, res *Response, next *Next) {
+		if errors.Is(err, errCause) {
+			res.Status(503).Send(err.Error())
+		}
+	})
+
+	// the error of a context past its deadline is passed to error handlers without a cause
+	app.Get("/deadline", func(req *Request, res *Response, next *Next) {
+		ctx, cancel := context.WithTimeout(req.Context(), 0)
+		req.WithContext(ctx)
+		res.OnFinish(cancel)
+		next.Next = true
+	}, func(req *Request, res *Response, next *Next) {
+		reached = true
+		res.Send("not reached")
+	})
+	app.UseError("/deadline", func(err error, req *Request, res *Response, next *Next) {
+		if errors.Is(err, context.DeadlineExceeded) {
+			res.Status(504).Send(err.Error())
+		}
+	})
+
+	app.Get("/cancel", func(req *Request, res *Response, next *Next) {
+		ctx, cancel := context.WithCancel(req.Context())
+		req.WithContext(ctx)
+		cancel()
+		next.Next = true
+	}, func(req *Request, res *Response, next *Next) {
+		reached = true
+		res.Send("not reached")
+	})
+
+	if status, body := serve(app, "GET", "/cause"); status != 503 || body != errCause.Error() || reached {
+		t.Errorf("/cause: expected 503 %q, got %d %q, reached: %v", errCause.Error(), status, body, reached)
+	}
+	if status, body := serve(app, "GET", "/deadline"); status != 504 || body != context.DeadlineExceeded.Error() || reached {
+		t.Errorf("/deadline: expected 504 %q, got %d %q, reached: %v", context.DeadlineExceeded.Error(), status, body, reached)
+	}
+	if status, body := serve(app, "GET", "/cancel"); status != 200 || body != "" || reached {
+		t.Errorf("/cancel: expected an empty 200, got %d %q, reached: %v", status, body, reached)
+	}
+}